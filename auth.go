@@ -0,0 +1,34 @@
+package servicehandler
+
+import (
+	"context"
+)
+
+// Authorizer decides if the caller in ctx may call a method with the params.
+// It runs after validation and before any Interceptor. Return ErrUnauthorized
+// when the caller isn't known and ErrForbidden when they lack the roles or
+// scopes in info. Other errors are handled like service errors.
+type Authorizer interface {
+	Authorize(ctx context.Context, info MethodInfo, params interface{}) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(ctx context.Context, info MethodInfo, params interface{}) error
+
+// Authorize calls f(ctx, info, params)
+func (f AuthorizerFunc) Authorize(ctx context.Context, info MethodInfo, params interface{}) error {
+	return f(ctx, info, params)
+}
+
+// authorize checks access before calling the invoker
+func authorize(invoke Invoker, info MethodInfo, a Authorizer) Invoker {
+	if a == nil {
+		return invoke
+	}
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		if err := a.Authorize(ctx, info, params); err != nil {
+			return nil, err
+		}
+		return invoke(ctx, params)
+	}
+}
@@ -0,0 +1,120 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testRoleKey struct{}
+
+// Callers have a single role in the context
+var testAuthorizer = AuthorizerFunc(func(ctx context.Context, info MethodInfo, params interface{}) error {
+	role, _ := ctx.Value(testRoleKey{}).(string)
+	if role == "" {
+		return ErrUnauthorized
+	}
+
+	for _, r := range info.Roles {
+		if r == role {
+			return nil
+		}
+	}
+
+	if len(info.Roles) > 0 {
+		return ErrForbidden
+	}
+
+	// Users may only load themselves
+	if p, ok := params.(struct {
+		ID int `valid:"required"`
+	}); ok && info.Name == "Get" && role != "admin" && p.ID != 1 {
+		return ErrForbidden
+	}
+
+	return nil
+})
+
+func TestAuthorizer(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{},
+		WithAuthorizer(testAuthorizer),
+		Method("Remove", WithRoles("admin")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Role     string
+		URL      string
+		Status   int
+		Response string
+	}{
+		{
+			URL:      "/Get?ID=1",
+			Status:   401,
+			Response: `{"success":false,"error":"unauthorized"}`,
+		},
+		{
+			Role:     "user",
+			URL:      "/Get?ID=1",
+			Status:   200,
+			Response: `{"success":true,"data":{"Name":"John","Email":""}}`,
+		},
+		{
+			Role:     "user",
+			URL:      "/Get?ID=2",
+			Status:   403,
+			Response: `{"success":false,"error":"forbidden"}`,
+		},
+		{
+			Role:     "user",
+			URL:      "/Remove?ID=2",
+			Status:   403,
+			Response: `{"success":false,"error":"forbidden"}`,
+		},
+		{
+			Role:     "admin",
+			URL:      "/Remove?ID=2",
+			Status:   200,
+			Response: `{"success":true}`,
+		},
+		{
+			// Invalid requests are rejected before authorization
+			URL:      "/Get",
+			Status:   400,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"ID":"non zero value required"}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("GET", s.URL, nil)
+		req = req.WithContext(context.WithValue(req.Context(), testRoleKey{}, s.Role))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.Status {
+			t.Errorf("%s as %q: wrong status: got %d want %d", s.URL, s.Role, rr.Code, s.Status)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s as %q: wrong response:\ngot  %s\nwant %s", s.URL, s.Role, got, s.Response)
+		}
+	}
+}
+
+func TestRolesWithoutAuthorizer(t *testing.T) {
+	_, err := Wrap(&TestUserService{}, Method("Remove", WithRoles("admin")))
+	if err == nil {
+		t.Error("Expected an error for roles without an Authorizer")
+	}
+}
+
+func TestUnknownMethodOptions(t *testing.T) {
+	_, err := Wrap(&TestUserService{}, WithAuthorizer(testAuthorizer), Method("Delet", WithRoles("admin")))
+	if err == nil || !strings.Contains(err.Error(), "Delet") {
+		t.Errorf("Expected an error for the unknown method, got %v", err)
+	}
+}
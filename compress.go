@@ -0,0 +1,187 @@
+package servicehandler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionTypes are the content types compressed by WithCompression
+// when no types are given. Entries ending in "/" match the whole type.
+var DefaultCompressionTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/",
+}
+
+// compression settings (see WithCompression)
+type compression struct {
+	minSize int
+	types   []string
+}
+
+// allowed reports if the content type is worth compressing
+func (c *compression) allowed(contentType string) bool {
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+
+	for _, t := range c.types {
+		if t == contentType || (strings.HasSuffix(t, "/") && strings.HasPrefix(contentType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptEncoding picks gzip or deflate from the Accept-Encoding header
+func acceptEncoding(r *http.Request) string {
+	var gzipQ, deflateQ float64 = -1, -1
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch name {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "deflate":
+			deflateQ = q
+		case "*":
+			if gzipQ == -1 {
+				gzipQ = q
+			}
+		}
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// newCompressor for the encoding. HTTP "deflate" is zlib wrapped (RFC 1950),
+// not the raw flate data of RFC 1951.
+func newCompressor(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "deflate" {
+		return zlib.NewWriter(w)
+	}
+	return gzip.NewWriter(w)
+}
+
+// negotiate the encoding of a response body of size bytes, "" to send it as
+// is. Vary is added whenever the answer depends on Accept-Encoding so a 304
+// carries it too.
+func (c *compression) negotiate(w http.ResponseWriter, r *http.Request, contentType string, size int) string {
+	if r.Method == http.MethodHead || w.Header().Get("Content-Encoding") != "" || !c.allowed(contentType) {
+		return ""
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := acceptEncoding(r)
+	if size < c.minSize {
+		return ""
+	}
+	return encoding
+}
+
+// encodedETag marks the ETag of a compressed body as a different
+// representation. Done before a 304 so it matches the 200 it validates.
+func encodedETag(w http.ResponseWriter, encoding string) {
+	if etag := w.Header().Get("ETag"); encoding != "" && strings.HasSuffix(etag, `"`) {
+		w.Header().Set("ETag", etag[:len(etag)-1]+"-"+encoding+`"`)
+	}
+}
+
+// compressBuffer compresses an encoded response with the negotiated encoding.
+// The caller owns both buffers.
+func compressBuffer(w http.ResponseWriter, encoding string, b *bytes.Buffer) *bytes.Buffer {
+	cb := bufferPool.Get().(*bytes.Buffer)
+	cw := newCompressor(encoding, cb)
+	_, _ = cw.Write(b.Bytes())
+	_ = cw.Close()
+
+	w.Header().Set("Content-Encoding", encoding)
+	return cb
+}
+
+// compressWriter compresses responses written by http.ServeContent and other
+// unbuffered writers once the headers show it is worthwhile
+type compressWriter struct {
+	http.ResponseWriter
+	c           *compression
+	encoding    string
+	w           io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if status == http.StatusOK && h.Get("Content-Encoding") == "" && cw.c.allowed(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+
+		size, err := strconv.Atoi(h.Get("Content-Length"))
+		if cw.encoding != "" && (err != nil || size >= cw.c.minSize) {
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			cw.w = newCompressor(cw.encoding, cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w != nil {
+		return cw.w.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Close flushes the compressed data
+func (cw *compressWriter) Close() error {
+	if cw.w != nil {
+		return cw.w.Close()
+	}
+	return nil
+}
+
+// compressDownload wraps w unless compression is off or the client asked for
+// a byte range (which refers to the uncompressed content)
+func (c *config) compressDownload(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if c.compression == nil || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+		return w, func() {}
+	}
+
+	cw := &compressWriter{ResponseWriter: w, c: c.compression, encoding: acceptEncoding(r)}
+	return cw, func() { _ = cw.Close() }
+}
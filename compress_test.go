@@ -0,0 +1,188 @@
+package servicehandler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+
+	scenarios := map[string]string{
+		"":                         "",
+		"gzip":                     "gzip",
+		"deflate":                  "deflate",
+		"deflate, gzip":            "gzip",
+		"gzip;q=0.5, deflate":      "deflate",
+		"gzip;q=0":                 "",
+		"br, *":                    "gzip",
+		"identity":                 "",
+		"x-gzip;q=1, deflate;q=.9": "gzip",
+	}
+
+	for header, want := range scenarios {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if got := acceptEncoding(req); got != want {
+			t.Errorf("%q: got %q want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+
+	scenarios := []struct {
+		Name     string
+		MinSize  int
+		URL      string
+		Header   http.Header
+		Encoding string
+	}{
+		{
+			Name:     "Gzip",
+			URL:      "/Recent",
+			Header:   http.Header{"Accept-Encoding": {"gzip"}},
+			Encoding: "gzip",
+		},
+		{
+			Name:     "Deflate",
+			URL:      "/Recent",
+			Header:   http.Header{"Accept-Encoding": {"deflate"}},
+			Encoding: "deflate",
+		},
+		{
+			Name: "Not Accepted",
+			URL:  "/Recent",
+		},
+		{
+			Name:    "Too Small",
+			MinSize: 1024,
+			URL:     "/Recent",
+			Header:  http.Header{"Accept-Encoding": {"gzip"}},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			mux, err := Wrap(&TestUserService{}, WithCompression(s.MinSize), WithETags())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", s.URL, nil)
+			for k, v := range s.Header {
+				req.Header[k] = v
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if enc := rr.Header().Get("Content-Encoding"); enc != s.Encoding {
+				t.Fatalf("Wrong Content-Encoding: got %q want %q", enc, s.Encoding)
+			}
+
+			if vary := rr.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Wrong Vary: %q", vary)
+			}
+
+			if s.Encoding != "" && !strings.HasSuffix(rr.Header().Get("ETag"), "-"+s.Encoding+`"`) {
+				t.Errorf("Wrong ETag: %q", rr.Header().Get("ETag"))
+			}
+
+			body := decompress(t, s.Encoding, rr.Body)
+			if !strings.HasPrefix(body, `{"success":true,"data":[{"Name":"Alice"`) {
+				t.Errorf("Wrong response: %q", body)
+			}
+		})
+	}
+}
+
+func TestCompressedNotModified(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithCompression(0), WithETags())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Recent", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	etag := rr.Header().Get("ETag")
+	if !strings.HasSuffix(etag, `-gzip"`) {
+		t.Fatalf("Wrong ETag: %q", etag)
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("Wrong status: %d", rr.Code)
+	}
+	if got := rr.Header().Get("ETag"); got != etag {
+		t.Errorf("Wrong ETag: got %q want %q", got, etag)
+	}
+	if vary := rr.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Wrong Vary: %q", vary)
+	}
+	if enc := rr.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("304 sent with Content-Encoding %q", enc)
+	}
+}
+
+func TestCompressedDownloads(t *testing.T) {
+
+	mux, err := Wrap(&FileService{}, WithCompression(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Report", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if enc := rr.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("Wrong Content-Encoding: %q", enc)
+	}
+
+	if body := decompress(t, "gzip", rr.Body); body != "hello world" {
+		t.Errorf("Wrong response: %q", body)
+	}
+
+	// Ranges are of the uncompressed file
+	req.Header.Set("Range", "bytes=0-4")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if enc := rr.Header().Get("Content-Encoding"); enc != "" || rr.Body.String() != "hello" {
+		t.Errorf("Wrong response: %q %q", enc, rr.Body.String())
+	}
+}
+
+func decompress(t *testing.T, encoding string, r io.Reader) string {
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "deflate":
+		r, err = zlib.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
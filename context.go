@@ -0,0 +1,115 @@
+package servicehandler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// RequestIDHeader is read by RequestID for an ID set by a proxy or client
+const RequestIDHeader = "X-Request-ID"
+
+// ContextFunc adds values from the request to the context given to services
+// (see WithContext)
+type ContextFunc func(r *http.Request, ctx context.Context) context.Context
+
+type requestKey struct{}
+
+// requestValues are added to every request context by the handler
+type requestValues struct {
+	r       *http.Request
+	once    sync.Once
+	id      string
+	started bool // a stream or download sent its status
+}
+
+type principalKey struct{}
+
+// requestContext adds the request, a ResponseControl and the WithContext
+// values to r
+func (c *config) requestContext(r *http.Request) *http.Request {
+	values := &requestValues{}
+	ctx := context.WithValue(r.Context(), requestKey{}, values)
+	ctx, rc := WithResponseControl(ctx)
+	for _, f := range c.contexts {
+		ctx = f(r, ctx)
+	}
+
+	// A ContextFunc can return a context not derived from the one it was given
+	if v, _ := ctx.Value(requestKey{}).(*requestValues); v != values {
+		ctx = context.WithValue(ctx, requestKey{}, values)
+	}
+	if responseControl(ctx) != rc {
+		ctx = context.WithValue(ctx, responseKey{}, rc)
+	}
+
+	r = r.WithContext(ctx)
+	values.r = r
+	return r
+}
+
+// startResponse marks the status and headers as sent by a stream or download,
+// after which a panic can't be answered with an error response
+func startResponse(r *http.Request) {
+	if values, ok := r.Context().Value(requestKey{}).(*requestValues); ok {
+		values.started = true
+	}
+}
+
+func responseStarted(r *http.Request) bool {
+	values, ok := r.Context().Value(requestKey{}).(*requestValues)
+	return ok && values.started
+}
+
+// RequestFromContext returns the HTTP request a service method was called for
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	values, ok := ctx.Value(requestKey{}).(*requestValues)
+	if !ok || values.r == nil {
+		return nil, false
+	}
+	return values.r, true
+}
+
+// RemoteIP of the client without the port. Behind a proxy use middleware that
+// sets http.Request.RemoteAddr from the forwarded headers you trust.
+func RemoteIP(ctx context.Context) string {
+	r, ok := RequestFromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RequestID from the X-Request-ID header or else a random ID which stays the
+// same for the whole request
+func RequestID(ctx context.Context) string {
+	values, ok := ctx.Value(requestKey{}).(*requestValues)
+	if !ok || values.r == nil {
+		return ""
+	}
+
+	values.once.Do(func() {
+		values.id = values.r.Header.Get(RequestIDHeader)
+		if values.id == "" {
+			values.id = newErrorID()
+		}
+	})
+	return values.id
+}
+
+// WithPrincipal stores the authenticated caller (i.e. a user or token claims),
+// usually from a WithContext function, for Principal to return
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal set with WithPrincipal or nil for anonymous requests
+func Principal(ctx context.Context) interface{} {
+	return ctx.Value(principalKey{})
+}
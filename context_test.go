@@ -0,0 +1,98 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type ContextService struct{}
+
+type RequestFacts struct {
+	IP        string
+	RequestID string
+	Principal interface{}
+	Agent     string
+}
+
+func (s *ContextService) Whoami(ctx context.Context, params struct{}) (RequestFacts, error) {
+	facts := RequestFacts{
+		IP:        RemoteIP(ctx),
+		RequestID: RequestID(ctx),
+		Principal: Principal(ctx),
+	}
+	if r, ok := RequestFromContext(ctx); ok {
+		facts.Agent = r.UserAgent()
+	}
+	return facts, nil
+}
+
+func TestRequestContext(t *testing.T) {
+
+	mux, err := Wrap(&ContextService{},
+		WithContext(func(r *http.Request, ctx context.Context) context.Context {
+			if token := r.Header.Get("Authorization"); token != "" {
+				ctx = WithPrincipal(ctx, strings.TrimPrefix(token, "Bearer "))
+			}
+			return ctx
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Whoami", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("Authorization", "Bearer alice")
+	req.Header.Set(RequestIDHeader, "abc123")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	want := `{"success":true,"data":{"IP":"192.0.2.1","RequestID":"abc123","Principal":"alice","Agent":"test"}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("Wrong response:\ngot  %s\nwant %s", got, want)
+	}
+
+	// Anonymous without a request ID
+	req = httptest.NewRequest("GET", "/Whoami", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), `"Principal":null`) || strings.Contains(rr.Body.String(), `"RequestID":""`) {
+		t.Errorf("Wrong response: %s", rr.Body.String())
+	}
+}
+
+func TestRequestContextNewContext(t *testing.T) {
+
+	mux, err := Wrap(&ContextService{},
+		WithContext(func(r *http.Request, ctx context.Context) context.Context {
+			return WithPrincipal(context.Background(), "alice")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Whoami", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set(RequestIDHeader, "abc123")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	want := `{"success":true,"data":{"IP":"192.0.2.1","RequestID":"abc123","Principal":"alice","Agent":"test"}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("Wrong response:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestContextHelpersOutsideHandler(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := RequestFromContext(ctx); ok || RemoteIP(ctx) != "" || RequestID(ctx) != "" || Principal(ctx) != nil {
+		t.Error("Expected empty values without a request")
+	}
+}
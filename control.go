@@ -0,0 +1,119 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// ResponseControl holds the headers, cookies and status a service set with
+// SetHeader, SetCookie and SetStatus. The handler adds one to every request
+// context and applies it when writing the response.
+type ResponseControl struct {
+	mu      sync.Mutex
+	header  http.Header
+	cookies []*http.Cookie
+	status  int
+}
+
+type responseKey struct{}
+
+// WithResponseControl adds a ResponseControl to the context. Use it to test
+// what a service sets outside of the handler.
+func WithResponseControl(ctx context.Context) (context.Context, *ResponseControl) {
+	rc := &ResponseControl{header: make(http.Header)}
+	return context.WithValue(ctx, responseKey{}, rc), rc
+}
+
+func responseControl(ctx context.Context) *ResponseControl {
+	rc, _ := ctx.Value(responseKey{}).(*ResponseControl)
+	return rc
+}
+
+// SetHeader sets a response header. Without a ResponseControl in the context
+// it does nothing.
+func SetHeader(ctx context.Context, key, value string) {
+	if rc := responseControl(ctx); rc != nil {
+		rc.mu.Lock()
+		rc.header.Set(key, value)
+		rc.mu.Unlock()
+	}
+}
+
+// SetCookie adds a Set-Cookie header to the response
+func SetCookie(ctx context.Context, cookie *http.Cookie) {
+	if rc := responseControl(ctx); rc != nil {
+		rc.mu.Lock()
+		rc.cookies = append(rc.cookies, cookie)
+		rc.mu.Unlock()
+	}
+}
+
+// SetStatus replaces the 200 (or 204) of a successful JSON response, i.e. with
+// 201 or 202. Errors, streams and downloads keep their status. Only 2xx
+// statuses that allow a body are used, others (like 204 or 304) are ignored.
+func SetStatus(ctx context.Context, status int) {
+	if !bodyAllowed(status) {
+		return
+	}
+	if rc := responseControl(ctx); rc != nil {
+		rc.mu.Lock()
+		rc.status = status
+		rc.mu.Unlock()
+	}
+}
+
+// bodyAllowed for a successful response with this status
+func bodyAllowed(status int) bool {
+	return status >= 200 && status < 300 &&
+		status != http.StatusNoContent && status != http.StatusResetContent
+}
+
+// Header returns a copy of the headers set
+func (rc *ResponseControl) Header() http.Header {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.header.Clone()
+}
+
+// Cookies set so far
+func (rc *ResponseControl) Cookies() []*http.Cookie {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]*http.Cookie(nil), rc.cookies...)
+}
+
+// Status set or 0
+func (rc *ResponseControl) Status() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.status
+}
+
+// apply the headers and cookies to w before anything is written. A nil
+// ResponseControl has nothing to apply.
+func (rc *ResponseControl) apply(w http.ResponseWriter) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for key, values := range rc.header {
+		w.Header()[key] = values
+	}
+	for _, cookie := range rc.cookies {
+		http.SetCookie(w, cookie)
+	}
+}
+
+// successStatus is the status set by the service or else status
+func successStatus(r *http.Request, status int) int {
+	if rc := responseControl(r.Context()); rc != nil {
+		if s := rc.Status(); s != 0 {
+			return s
+		}
+	}
+	return status
+}
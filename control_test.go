@@ -0,0 +1,147 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type SessionService struct{}
+
+type Login struct {
+	Name string `valid:"required"`
+}
+
+func (s *SessionService) Login(ctx context.Context, l *Login) (string, error) {
+	SetCookie(ctx, &http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+	SetHeader(ctx, "Location", "/Me")
+	SetStatus(ctx, http.StatusCreated)
+
+	if l.Name == "locked" {
+		return "", ErrForbidden
+	}
+	return "welcome " + l.Name, nil
+}
+
+func TestResponseControl(t *testing.T) {
+
+	mux, err := Wrap(&SessionService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Body     string
+		Status   int
+		Response string
+	}{
+		{
+			Body:     `{"Name":"alice"}`,
+			Status:   201,
+			Response: `{"success":true,"data":"welcome alice"}`,
+		},
+		{
+			Body:     `{"Name":"locked"}`,
+			Status:   403,
+			Response: `{"success":false,"error":"forbidden"}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("POST", "/Login", strings.NewReader(s.Body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.Status {
+			t.Errorf("%s: wrong status: got %d want %d", s.Body, rr.Code, s.Status)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s: wrong response:\ngot  %s\nwant %s", s.Body, got, s.Response)
+		}
+
+		if cookie := rr.Header().Get("Set-Cookie"); cookie != "session=abc; HttpOnly" {
+			t.Errorf("%s: wrong Set-Cookie: %q", s.Body, cookie)
+		}
+
+		if location := rr.Header().Get("Location"); location != "/Me" {
+			t.Errorf("%s: wrong Location: %q", s.Body, location)
+		}
+	}
+}
+
+// Services can be tested without the handler
+func TestResponseControlWithoutHandler(t *testing.T) {
+	ctx, rc := WithResponseControl(context.Background())
+
+	_, err := (&SessionService{}).Login(ctx, &Login{Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rc.Status() != http.StatusCreated || rc.Header().Get("Location") != "/Me" || len(rc.Cookies()) != 1 {
+		t.Errorf("Wrong response control: %d %v %v", rc.Status(), rc.Header(), rc.Cookies())
+	}
+
+	// No-ops without a ResponseControl
+	SetStatus(context.Background(), http.StatusAccepted)
+}
+
+// Statuses without a body would drop the result
+func (s *SessionService) Logout(ctx context.Context, params struct {
+	Status int
+}) (string, error) {
+	SetStatus(ctx, params.Status)
+	return "bye", nil
+}
+
+func TestSetStatusWithoutBody(t *testing.T) {
+
+	mux, err := Wrap(&SessionService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []int{204, 205, 304, 404, 99} {
+		req := httptest.NewRequest("GET", "/Logout?Status="+strconv.Itoa(status), nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"success":true,"data":"bye"}` {
+			t.Errorf("%d: wrong response %d: %s", status, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestResponseControlNewContext(t *testing.T) {
+
+	// A ContextFunc that drops the handler's values
+	mux, err := Wrap(&SessionService{},
+		WithContext(func(r *http.Request, ctx context.Context) context.Context {
+			return context.Background()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/Login", strings.NewReader(`{"Name":"alice"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Wrong status: %d", rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "/Me" {
+		t.Errorf("Wrong Location: %q", location)
+	}
+	if cookies := rr.Result().Cookies(); len(cookies) != 1 {
+		t.Errorf("Wrong cookies: %v", cookies)
+	}
+
+	var rc *ResponseControl
+	rc.apply(httptest.NewRecorder())
+}
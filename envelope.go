@@ -0,0 +1,173 @@
+package servicehandler
+
+import (
+	"net/http"
+)
+
+// ResponseError is what the client is told about a request that failed. Err
+// holds the original error (if any) which is never sent to the client.
+type ResponseError struct {
+	Status  int
+	Message string
+	Fields  FieldErrors       // Invalid fields
+	Codes   map[string]string // Failed rule of each field
+	Code    string            // Machine readable error code
+	ErrorID string            // ID the internal error was logged under
+	Stack   string            // Of a recovered panic, only set in dev mode
+	Err     error
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.Status)
+	}
+	return e.Message
+}
+
+// HTTPStatus the response is sent with
+func (e *ResponseError) HTTPStatus() int {
+	return e.Status
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// Envelope builds the response body from the result of a service method or
+// the error for a failed request (validation, unknown method, service error)
+type Envelope func(r *http.Request, result interface{}, err *ResponseError) interface{}
+
+// NewJSONResponse is the default envelope
+func NewJSONResponse(r *http.Request, result interface{}, err *ResponseError) interface{} {
+	if err != nil {
+		return JSONResponse{
+			Success: false,
+			Error:   err.Error(),
+			Fields:  err.Fields,
+			Codes:   err.Codes,
+			Code:    err.Code,
+			ErrorID: err.ErrorID,
+			Stack:   err.Stack,
+		}
+	}
+
+	if p, ok := result.(PageResult); ok {
+		return JSONResponse{
+			Success: true,
+			Data:    p.Items,
+			Meta:    p.Meta,
+		}
+	}
+
+	return JSONResponse{
+		Success: true,
+		Data:    result,
+	}
+}
+
+// render the response body in the configured envelope
+func (c *config) render(r *http.Request, result interface{}, err *ResponseError) (status int, contentType string, body interface{}) {
+	status = http.StatusOK
+	if err != nil {
+		status = err.Status
+	}
+
+	contentType = "application/json"
+
+	switch {
+	case c.envelope != nil:
+		body = c.envelope(r, result, err)
+	case err != nil && c.problems:
+		contentType = "application/problem+json"
+		body = NewProblem(r, err)
+	case err == nil && c.raw:
+		body = result
+		if p, ok := result.(PageResult); ok {
+			body = p.Items
+		}
+	default:
+		body = NewJSONResponse(r, result, err)
+	}
+
+	return
+}
+
+// write the response to the client. The body is encoded before anything is
+// written so a result that can't be encoded (i.e. NaN or a channel) is sent
+// as a clean 500 error instead of half a JSON document.
+func (c *config) write(w http.ResponseWriter, r *http.Request, result interface{}, err *ResponseError) {
+	status, contentType, body := c.render(r, result, err)
+	if err == nil {
+		status = successStatus(r, status)
+	}
+
+	b, encodeErr := encodeJSON(body)
+	if encodeErr != nil {
+		putBuffer(b)
+
+		id := newErrorID()
+		c.logger.Printf("servicehandler: error %s encoding response to %s: %v", id, r.URL.Path, encodeErr)
+
+		status, contentType, body = c.render(r, nil, &ResponseError{
+			Status:  http.StatusInternalServerError,
+			ErrorID: id,
+			Err:     encodeErr,
+		})
+
+		// A custom envelope could fail again
+		b, encodeErr = encodeJSON(body)
+		if encodeErr != nil {
+			putBuffer(b)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Negotiated first so a 304 has the same Vary and ETag as the 200
+	var encoding string
+	if c.compression != nil {
+		encoding = c.compression.negotiate(w, r, contentType, b.Len())
+	}
+
+	// Only successes are cached, not the 500 of a failed encoding
+	if err == nil && status >= 200 && status < 300 && cacheable(r) {
+		if c.cacheControl != "" {
+			w.Header().Set("Cache-Control", c.cacheControl)
+		}
+
+		if c.etags {
+			unchanged := notModified(w, r, result, b)
+			encodedETag(w, encoding)
+			if unchanged {
+				putBuffer(b)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	if encoding != "" {
+		cb := compressBuffer(w, encoding, b)
+		putBuffer(b)
+		b = cb
+	}
+
+	writeBuffer(w, status, contentType, b)
+	putBuffer(b)
+}
+
+// writeNoContent responds to an error-only method that succeeded with 204 No
+// Content or, if there is an envelope, {"success":true}
+func (c *config) writeNoContent(w http.ResponseWriter, r *http.Request) {
+	if c.raw && c.envelope == nil {
+		w.WriteHeader(successStatus(r, http.StatusNoContent))
+		return
+	}
+
+	c.write(w, r, nil, nil)
+}
+
+// writeStatus responds with just the HTTP status (i.e. 404 or 405)
+func (c *config) writeStatus(w http.ResponseWriter, r *http.Request, status int) {
+	c.write(w, r, nil, &ResponseError{Status: status})
+}
@@ -0,0 +1,207 @@
+package servicehandler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// Errors services can return (or wrap with fmt.Errorf("...%w", err)) to send
+// the matching HTTP status code instead of a 500
+var (
+	ErrNotFound     error = &StatusError{Status: http.StatusNotFound, Message: "not found"}
+	ErrConflict     error = &StatusError{Status: http.StatusConflict, Message: "conflict"}
+	ErrUnauthorized error = &StatusError{Status: http.StatusUnauthorized, Message: "unauthorized"}
+	ErrForbidden    error = &StatusError{Status: http.StatusForbidden, Message: "forbidden"}
+)
+
+// HTTPStatuser is implemented by errors that know their HTTP status code
+type HTTPStatuser interface {
+	HTTPStatus() int
+}
+
+// StatusError is an error sent to the client with the given HTTP status code
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.Status)
+	}
+	return e.Message
+}
+
+// HTTPStatus code of the error
+func (e *StatusError) HTTPStatus() int {
+	return e.Status
+}
+
+// errorStatus finds the HTTP status of the first error in the chain that
+// has one. Anything else, including a status that isn't an error (like 0 or
+// 200), is an Internal Server Error.
+func errorStatus(err error) int {
+	var s HTTPStatuser
+	if errors.As(err, &s) && isErrorStatus(s.HTTPStatus()) {
+		return s.HTTPStatus()
+	}
+	return http.StatusInternalServerError
+}
+
+// hasStatus reports if the error has a usable 4xx or 5xx status
+func hasStatus(err error) bool {
+	var s HTTPStatuser
+	return errors.As(err, &s) && isErrorStatus(s.HTTPStatus())
+}
+
+func isErrorStatus(status int) bool {
+	return status >= 400 && status <= 599
+}
+
+// Public is implemented by errors with a message that is safe to send to the
+// client. All other errors are logged and replaced by a generic message.
+type Public interface {
+	Public() bool
+}
+
+// Public errors are sent as-is
+func (e *StatusError) Public() bool {
+	return true
+}
+
+// PublicError marks err as safe to send to the client
+func PublicError(err error) error {
+	return publicError{err}
+}
+
+type publicError struct {
+	error
+}
+
+func (e publicError) Public() bool {
+	return true
+}
+
+func (e publicError) Unwrap() error {
+	return e.error
+}
+
+// publicMessage is the message of the public error in the chain. Context
+// added around it with fmt.Errorf (queries, paths, IDs) is never sent.
+func publicMessage(err error) (string, bool) {
+	var p Public
+	if !errors.As(err, &p) || !p.Public() {
+		return "", false
+	}
+	if e, ok := p.(error); ok {
+		return e.Error(), true
+	}
+	return "", false
+}
+
+// newErrorID for matching the response a client got to the server logs
+func newErrorID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// serviceFailure turns an error returned by a service method into a response.
+// Internal errors are logged under an ID the client is given instead.
+func (c *config) serviceFailure(r *http.Request, method string, names map[string]string, err error) *ResponseError {
+	// Sent just like the validator's errors
+	var fields FieldErrors
+	if errors.As(err, &fields) {
+		return &ResponseError{
+			Status:  http.StatusBadRequest,
+			Message: c.invalidRequest(r),
+			Fields:  renameFields(fields, names),
+			Err:     err,
+		}
+	}
+
+	status := errorStatus(err)
+
+	code := errorCode(err)
+	if code != "" && len(c.errorCodes) > 0 {
+		ec, ok := c.registeredCode(code)
+		if !ok {
+			c.logger.Printf("servicehandler: %s returned undeclared error code %q", method, code)
+		} else if isErrorStatus(ec.Status) && !hasStatus(err) {
+			status = ec.Status
+		}
+	}
+
+	if message, ok := publicMessage(err); ok {
+		return &ResponseError{
+			Status:  status,
+			Message: message,
+			Code:    code,
+			Err:     err,
+		}
+	}
+
+	id := newErrorID()
+	c.logger.Printf("servicehandler: error %s in %s: %v", id, method, err)
+
+	return &ResponseError{
+		Status:  status,
+		Code:    code,
+		ErrorID: id,
+		Err:     err,
+	}
+}
+
+// Coder is implemented by errors with a stable, machine readable code such as
+// "user.not_found" that clients can branch on instead of the message
+type Coder interface {
+	Code() string
+}
+
+// ErrorCode documents an error a method can return (see WithErrorCodes)
+type ErrorCode struct {
+	Code        string
+	Status      int
+	Description string
+}
+
+// CodedError adds a machine readable code to err
+func CodedError(code string, err error) error {
+	return codedError{err, code}
+}
+
+type codedError struct {
+	error
+	code string
+}
+
+func (e codedError) Code() string {
+	return e.code
+}
+
+func (e codedError) Unwrap() error {
+	return e.error
+}
+
+// errorCode of the first error in the chain that has one
+func errorCode(err error) string {
+	var c Coder
+	if errors.As(err, &c) {
+		return c.Code()
+	}
+	return ""
+}
+
+// registeredCode looks up a code in the method's registry
+func (c *config) registeredCode(code string) (ErrorCode, bool) {
+	for _, ec := range c.errorCodes {
+		if ec.Code == code {
+			return ec, true
+		}
+	}
+	return ErrorCode{}, false
+}
@@ -0,0 +1,328 @@
+package servicehandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "short and stout" }
+func (teapotError) HTTPStatus() int { return http.StatusTeapot }
+
+func TestErrorStatus(t *testing.T) {
+
+	scenarios := []struct {
+		Err    error
+		Status int
+	}{
+		{errors.New("boom"), http.StatusInternalServerError},
+		{ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("User 3 %w", ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("save: %w", ErrConflict), http.StatusConflict},
+		{ErrUnauthorized, http.StatusUnauthorized},
+		{ErrForbidden, http.StatusForbidden},
+		{&StatusError{Status: http.StatusGone}, http.StatusGone},
+		{fmt.Errorf("brew: %w", teapotError{}), http.StatusTeapot},
+		{&StatusError{Message: "oops"}, http.StatusInternalServerError},
+		{&StatusError{Status: http.StatusOK}, http.StatusInternalServerError},
+		{&StatusError{Status: 600}, http.StatusInternalServerError},
+	}
+
+	for _, s := range scenarios {
+		if got := errorStatus(s.Err); got != s.Status {
+			t.Errorf("%q: got %d want %d", s.Err, got, s.Status)
+		}
+	}
+}
+
+type ErrorService struct{}
+
+func (s *ErrorService) Internal(ctx context.Context, params struct{}) error {
+	return fmt.Errorf("load users: %w", errors.New("pq: relation \"users\" does not exist"))
+}
+
+func (s *ErrorService) Public(ctx context.Context, params struct{}) error {
+	return PublicError(errors.New("Try again later"))
+}
+
+func TestInternalErrors(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&ErrorService{}, WithErrorLog(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Internal", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+
+	var response JSONResponse
+	if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Error != "Internal Server Error" {
+		t.Errorf("Internal error leaked: %s", response.Error)
+	}
+
+	if response.ErrorID == "" {
+		t.Fatal("Missing error ID")
+	}
+
+	want := "servicehandler: error " + response.ErrorID + " in ErrorService.Internal: load users: pq: relation \"users\" does not exist"
+	if got := strings.TrimSpace(logs.String()); got != want {
+		t.Errorf("Wrong log:\ngot %s\nwant %s", got, want)
+	}
+
+	req = httptest.NewRequest("GET", "/Public", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	got := strings.TrimSpace(rr.Body.String())
+	if want := `{"success":false,"error":"Try again later"}`; got != want {
+		t.Errorf("Wrong response:\ngot %s\nwant %s", got, want)
+	}
+}
+
+func (s *ErrorService) Coded(ctx context.Context, params struct {
+	Code string
+}) error {
+	return CodedError(params.Code, PublicError(errors.New("User is banned")))
+}
+
+func TestErrorCodes(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&ErrorService{},
+		WithErrorLog(log.New(&logs, "", 0)),
+		Method("Coded", WithErrorCodes(ErrorCode{
+			Code:        "user.banned",
+			Status:      http.StatusForbidden,
+			Description: "The user was banned by a moderator",
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Code       string
+		StatusCode int
+		Response   string
+		Log        string
+	}{
+		{
+			Code:       "user.banned",
+			StatusCode: http.StatusForbidden,
+			Response:   `{"success":false,"error":"User is banned","code":"user.banned"}`,
+		},
+		{
+			Code:       "user.unknown",
+			StatusCode: http.StatusInternalServerError,
+			Response:   `{"success":false,"error":"User is banned","code":"user.unknown"}`,
+			Log:        `servicehandler: ErrorService.Coded returned undeclared error code "user.unknown"`,
+		},
+	}
+
+	for _, s := range scenarios {
+		logs.Reset()
+
+		req := httptest.NewRequest("GET", "/Coded?Code="+s.Code, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.StatusCode {
+			t.Errorf("%s: wrong status code: got %v want %v", s.Code, rr.Code, s.StatusCode)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s: wrong response:\ngot %s\nwant %s", s.Code, got, s.Response)
+		}
+
+		if got := strings.TrimSpace(logs.String()); got != s.Log {
+			t.Errorf("%s: wrong log:\ngot %s\nwant %s", s.Code, got, s.Log)
+		}
+	}
+
+	// The registry can be published to frontends
+	for _, m := range Methods(mux) {
+		if m.Name != "Coded" {
+			if len(m.ErrorCodes) != 0 {
+				t.Errorf("%s: unexpected codes %v", m.Name, m.ErrorCodes)
+			}
+			continue
+		}
+		if len(m.ErrorCodes) != 1 || m.ErrorCodes[0].Description != "The user was banned by a moderator" {
+			t.Errorf("Wrong codes: %v", m.ErrorCodes)
+		}
+	}
+
+	if len(Methods(mux)) == 0 || Methods(http.NotFoundHandler()) != nil {
+		t.Error("Wrong Methods")
+	}
+}
+
+func (s *ErrorService) NaN(ctx context.Context, params struct{}) (float64, error) {
+	return math.NaN(), nil
+}
+
+func TestEncodeFailure(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&ErrorService{}, WithErrorLog(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/NaN", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+
+	if cl := rr.Header().Get("Content-Length"); cl != strconv.Itoa(rr.Body.Len()) {
+		t.Errorf("Wrong Content-Length: got %s want %d", cl, rr.Body.Len())
+	}
+
+	var response JSONResponse
+	if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", rr.Body.String(), err)
+	}
+
+	if response.Success || response.ErrorID == "" || !strings.Contains(logs.String(), response.ErrorID) {
+		t.Errorf("Wrong response: %s", rr.Body.String())
+	}
+}
+
+type LookupParams struct {
+	Name string
+}
+
+// Validate looks the name up like a hook checking a database would
+func (p *LookupParams) Validate(ctx context.Context) error {
+	switch p.Name {
+	case "banned":
+		return ErrForbidden
+	case "coded":
+		return CodedError("name.paused", PublicError(errors.New("Name lookups are paused")))
+	}
+	return errors.New("pq: connection to 10.0.0.5:5432 refused")
+}
+
+func (s *ErrorService) Lookup(ctx context.Context, p *LookupParams) error {
+	return nil
+}
+
+func TestHookErrors(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&ErrorService{}, WithErrorLog(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/Lookup", strings.NewReader(`{"Name":"alice"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "10.0.0.5") {
+		t.Errorf("Internal hook error leaked: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "in ErrorService.Lookup: pq: connection to 10.0.0.5:5432 refused") {
+		t.Errorf("Hook error not logged: %s", logs.String())
+	}
+
+	// Public errors keep their status and code
+	scenarios := map[string]struct {
+		Status   int
+		Response string
+	}{
+		"banned": {http.StatusForbidden, `{"success":false,"error":"forbidden"}`},
+		"coded":  {http.StatusInternalServerError, `{"success":false,"error":"Name lookups are paused","code":"name.paused"}`},
+	}
+
+	for name, s := range scenarios {
+		req = httptest.NewRequest("POST", "/Lookup", strings.NewReader(`{"Name":"`+name+`"}`))
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		got := strings.TrimSpace(rr.Body.String())
+		if rr.Code != s.Status || got != s.Response {
+			t.Errorf("%s: wrong response %d:\ngot %s\nwant %s", name, rr.Code, got, s.Response)
+		}
+	}
+}
+
+func (s *ErrorService) Wrapped(ctx context.Context, params struct{}) error {
+	return fmt.Errorf("select * from users where id=%d (%s): %w", 3, "postgres://app:secret@db", ErrNotFound)
+}
+
+func TestWrappedPublicErrors(t *testing.T) {
+
+	mux, err := Wrap(&ErrorService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Wrapped", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	// Only the public error's own message is sent
+	got := strings.TrimSpace(rr.Body.String())
+	if want := `{"success":false,"error":"not found"}`; rr.Code != http.StatusNotFound || got != want {
+		t.Errorf("Wrong response %d:\ngot %s\nwant %s", rr.Code, got, want)
+	}
+}
+
+func TestInvalidCodeStatus(t *testing.T) {
+	c := newConfig([]Option{
+		WithErrorLog(log.New(io.Discard, "", 0)),
+		WithErrorCodes(ErrorCode{Code: "odd", Status: http.StatusOK}),
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	e := c.serviceFailure(req, "Test", nil, CodedError("odd", errors.New("boom")))
+	if e.Status != http.StatusInternalServerError {
+		t.Errorf("Wrong status: %d", e.Status)
+	}
+}
+
+func TestMethodsCopy(t *testing.T) {
+	mux, err := Wrap(&ErrorService{}, Method("Coded", WithErrorCodes(ErrorCode{Code: "user.banned", Status: http.StatusForbidden})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range Methods(mux) {
+		if m.Name == "Coded" {
+			m.ErrorCodes[0].Status = http.StatusTeapot
+		}
+	}
+
+	for _, m := range Methods(mux) {
+		if m.Name == "Coded" && m.ErrorCodes[0].Status != http.StatusForbidden {
+			t.Error("Methods shares the handler's error codes")
+		}
+	}
+}
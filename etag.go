@@ -0,0 +1,85 @@
+package servicehandler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Results can provide their own validators instead of hashing the body
+type etagger interface {
+	ETag() string
+}
+
+type lastModifier interface {
+	LastModified() time.Time
+}
+
+// cacheable requests can be answered with 304 Not Modified
+func cacheable(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// notModified sets the ETag (and Last-Modified) headers for the encoded body
+// and reports if the client already has this version
+func notModified(w http.ResponseWriter, r *http.Request, result interface{}, body *bytes.Buffer) bool {
+	var etag string
+	if e, ok := result.(etagger); ok {
+		etag = e.ETag()
+		if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = `"` + etag + `"`
+		}
+	} else {
+		sum := sha256.Sum256(body.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	var modified time.Time
+	if lm, ok := result.(lastModifier); ok {
+		modified = lm.LastModified()
+		if !modified.IsZero() {
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	// If-None-Match takes precedence https://tools.ietf.org/html/rfc7232#section-6
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagMatch(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+// etagMatch uses the weak comparison If-None-Match requires. The suffix
+// added to the ETag of compressed responses is ignored.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || trimEncoding(strings.TrimPrefix(tag, "W/")) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func trimEncoding(etag string) string {
+	for _, suffix := range []string{`-gzip"`, `-deflate"`} {
+		if strings.HasSuffix(etag, suffix) {
+			return etag[:len(etag)-len(suffix)] + `"`
+		}
+	}
+	return etag
+}
@@ -0,0 +1,135 @@
+package servicehandler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type VersionedUser struct {
+	Name    string
+	Version int
+	Updated time.Time
+}
+
+func (u *VersionedUser) ETag() string            { return "v" + strconv.Itoa(u.Version) }
+func (u *VersionedUser) LastModified() time.Time { return u.Updated }
+
+type ETagService struct{}
+
+func (s *ETagService) Get(ctx context.Context, params struct{}) (*VersionedUser, error) {
+	return &VersionedUser{Name: "John", Version: 3, Updated: reportTime}, nil
+}
+
+func TestETags(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithETags(), Method("Get", WithCacheControl("max-age=60")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Get?ID=1", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	etag := rr.Header().Get("ETag")
+	if len(etag) != 34 {
+		t.Fatalf("Wrong ETag: %q", etag)
+	}
+
+	if cc := rr.Header().Get("Cache-Control"); cc != "max-age=60" {
+		t.Errorf("Wrong Cache-Control: %q", cc)
+	}
+
+	req = httptest.NewRequest("GET", "/Get?ID=1", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Wrong response: %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Other methods don't get the Cache-Control
+	req = httptest.NewRequest("GET", "/Recent", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if cc := rr.Header().Get("Cache-Control"); cc != "" {
+		t.Errorf("Wrong Cache-Control: %q", cc)
+	}
+}
+
+func TestResultValidators(t *testing.T) {
+
+	mux, err := Wrap(&ETagService{}, WithETags())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name       string
+		Header     string
+		Value      string
+		StatusCode int
+	}{
+		{"No Validators", "", "", http.StatusOK},
+		{"ETag Match", "If-None-Match", `W/"v3"`, http.StatusNotModified},
+		{"ETag Mismatch", "If-None-Match", `"v2"`, http.StatusOK},
+		{"Not Modified Since", "If-Modified-Since", reportTime.Format(http.TimeFormat), http.StatusNotModified},
+		{"Modified Since", "If-Modified-Since", reportTime.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("GET", "/Get", nil)
+		if s.Header != "" {
+			req.Header.Set(s.Header, s.Value)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.StatusCode {
+			t.Errorf("%s: wrong status code: got %v want %v", s.Name, rr.Code, s.StatusCode)
+		}
+
+		if etag := rr.Header().Get("ETag"); etag != `"v3"` {
+			t.Errorf("%s: wrong ETag: %q", s.Name, etag)
+		}
+
+		if lm := rr.Header().Get("Last-Modified"); lm != reportTime.Format(http.TimeFormat) {
+			t.Errorf("%s: wrong Last-Modified: %q", s.Name, lm)
+		}
+	}
+}
+
+func TestErrorsNotCached(t *testing.T) {
+
+	mux, err := Wrap(&ErrorService{},
+		WithErrorLog(log.New(io.Discard, "", 0)),
+		WithETags(),
+		WithCacheControl("public, max-age=3600"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Encoding the NaN result fails after the method succeeded
+	for _, url := range []string{"/NaN", "/Internal"} {
+		req := httptest.NewRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("%s: wrong status %d", url, rr.Code)
+		}
+
+		if cc, etag := rr.Header().Get("Cache-Control"), rr.Header().Get("ETag"); cc != "" || etag != "" {
+			t.Errorf("%s: error sent with Cache-Control %q and ETag %q", url, cc, etag)
+		}
+	}
+}
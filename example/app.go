@@ -47,7 +47,7 @@ func (s *UserService) Get(ctx context.Context, params struct {
 	}
 
 	// Extra checks here?
-	// Ensure we have permissions to access this user?
+	// Access rules belong in a servicehandler.Authorizer (see WithAuthorizer)
 
 	return user, nil
 }
@@ -106,3 +106,32 @@ func TestCreateUserFailure(t *testing.T) {
 	}
 
 }
+
+func TestGetUserNotFound(t *testing.T) {
+
+	req, err := http.NewRequest("GET", "/Get?ID=404", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	// Create HTTP mux/router
+	mux, err := setup()
+	if err != nil {
+		t.Error(err)
+	}
+
+	mux.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	response := strings.TrimSpace(rr.Body.String())
+	want := `{"success":false,"error":"not found"}`
+	if response != want {
+		t.Errorf("Wrong response:\ngot %s\nwant %s", response, want)
+	}
+
+}
@@ -1,7 +1,11 @@
 package main
 
-import "errors"
-import "sync/atomic"
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Xeoncross/servicehandler"
+)
 
 // Basic "database" that stores users in memory
 
@@ -28,7 +32,7 @@ func (s *MemoryStore) Save(u *User) (int32, error) {
 func (s *MemoryStore) GetByID(id int32) (*User, error) {
 	user, ok := s.users[id]
 	if !ok {
-		return nil, errors.New("User not found")
+		return nil, fmt.Errorf("User %d %w", id, servicehandler.ErrNotFound)
 	}
 	return user, nil
 }
@@ -0,0 +1,185 @@
+package servicehandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// FieldsParam is the query parameter for sparse fieldsets (see WithSparseFields)
+const FieldsParam = "fields"
+
+// fieldTree is a set of JSON paths like "name" and "author.email". A nil
+// subtree selects the whole value.
+type fieldTree map[string]fieldTree
+
+// allowedFields of a result type. Maps and interfaces can hold anything so
+// every path below them is allowed.
+type allowedFields struct {
+	any      bool
+	children map[string]*allowedFields // By lower case JSON name
+	names    []string                  // As encoded for error messages
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+var pagerType = reflect.TypeOf((*pager)(nil)).Elem()
+
+// itemType of a Page[T] result is what the fields are selected from
+func itemType(t reflect.Type) reflect.Type {
+	if t.Implements(pagerType) {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if field, ok := t.FieldByName("Items"); ok {
+			return field.Type
+		}
+	}
+	return t
+}
+
+// resultFields walks the JSON structure of a result type
+func resultFields(t reflect.Type, seen map[reflect.Type]*allowedFields) *allowedFields {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	if a, ok := seen[t]; ok {
+		return a
+	}
+
+	switch {
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Interface:
+		return &allowedFields{any: true}
+	case t.Kind() != reflect.Struct || t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &allowedFields{}
+	}
+
+	a := &allowedFields{children: make(map[string]*allowedFields)}
+	seen[t] = a // Recursive types
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		name := field.Name
+		if n := jsonName(field); n != "" {
+			name = n
+		}
+
+		// Fields of embedded structs are promoted
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && jsonName(field) == "" && ft.Kind() == reflect.Struct {
+			embedded := resultFields(ft, seen)
+			for k, v := range embedded.children {
+				a.children[k] = v
+			}
+			a.names = append(a.names, embedded.names...)
+			continue
+		}
+
+		a.children[strings.ToLower(name)] = resultFields(field.Type, seen)
+		a.names = append(a.names, name)
+	}
+
+	return a
+}
+
+// parse "name,author.email" into a fieldTree checking each path is allowed
+func (a *allowedFields) parse(value string) (fieldTree, FieldErrors) {
+	tree := make(fieldTree)
+
+	for _, path := range strings.Split(value, ",") {
+		path = strings.ToLower(strings.TrimSpace(path))
+		if path == "" {
+			continue
+		}
+
+		allowed := a
+		node := tree
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			if !allowed.any {
+				next, ok := allowed.children[part]
+				if !ok {
+					return nil, FieldErrors{FieldsParam: "unknown field \"" + path + "\", allowed: " + strings.Join(allowed.names, ", ")}
+				}
+				allowed = next
+			}
+
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+
+			sub, ok := node[part]
+			if ok && sub == nil {
+				break // The whole value is already selected
+			}
+			if !ok {
+				sub = make(fieldTree)
+				node[part] = sub
+			}
+			node = sub
+		}
+	}
+
+	return tree, nil
+}
+
+// selectFields filters the JSON encoding of the result down to the tree. If
+// the result can't be encoded it is returned as-is for write() to report.
+func selectFields(result interface{}, tree fieldTree) interface{} {
+	if p, ok := result.(PageResult); ok {
+		p.Items = selectFields(p.Items, tree)
+		return p
+	}
+
+	b, err := encodeJSON(result)
+	defer putBuffer(b)
+	if err != nil {
+		return result
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b.Bytes()))
+	d.UseNumber()
+	if err = d.Decode(&v); err != nil {
+		return result
+	}
+
+	return filterValue(v, tree)
+}
+
+func filterValue(v interface{}, tree fieldTree) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = filterValue(v[i], tree)
+		}
+		return v
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(tree))
+		for k, value := range v {
+			sub, ok := tree[strings.ToLower(k)]
+			if !ok {
+				continue
+			}
+			if sub != nil {
+				value = filterValue(value, sub)
+			}
+			out[k] = value
+		}
+		return out
+	}
+	return v
+}
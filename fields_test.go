@@ -0,0 +1,107 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type TestPost struct {
+	ID       int         `json:"id"`
+	Title    string      `json:"title"`
+	Author   TestUser    `json:"author"`
+	Comments []TestUser  `json:"comments"`
+	Extra    interface{} `json:"extra,omitempty"`
+	secret   string
+}
+
+type PostService struct{}
+
+func (s *PostService) Latest(ctx context.Context, params struct{}) ([]TestPost, error) {
+	return []TestPost{
+		{
+			ID:       1,
+			Title:    "Hello",
+			Author:   TestUser{Name: "Alice", Email: "alice@example.com"},
+			Comments: []TestUser{{Name: "Bob", Email: "bob@example.com"}},
+			Extra:    map[string]int{"likes": 3, "shares": 1},
+		},
+	}, nil
+}
+
+func TestSparseFields(t *testing.T) {
+
+	mux, err := Wrap(&PostService{}, WithSparseFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		URL      string
+		Status   int
+		Response string
+	}{
+		{
+			URL:      "/Latest?fields=id,title",
+			Status:   200,
+			Response: `{"success":true,"data":[{"id":1,"title":"Hello"}]}`,
+		},
+		{
+			URL:      "/Latest?fields=title,author.name,comments.email",
+			Status:   200,
+			Response: `{"success":true,"data":[{"author":{"Name":"Alice"},"comments":[{"Email":"bob@example.com"}],"title":"Hello"}]}`,
+		},
+		{
+			URL:      "/Latest?fields=author.name,author",
+			Status:   200,
+			Response: `{"success":true,"data":[{"author":{"Email":"alice@example.com","Name":"Alice"}}]}`,
+		},
+		{
+			URL:      "/Latest?fields=extra.likes",
+			Status:   200,
+			Response: `{"success":true,"data":[{"extra":{"likes":3}}]}`,
+		},
+		{
+			URL:      "/Latest?fields=id,body",
+			Status:   400,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"fields":"unknown field \"body\", allowed: id, title, author, comments, extra"}}`,
+		},
+		{
+			URL:      "/Latest?fields=author.phone",
+			Status:   400,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"fields":"unknown field \"author.phone\", allowed: Name, Email"}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("GET", s.URL, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.Status {
+			t.Errorf("%s: wrong status: got %d want %d", s.URL, rr.Code, s.Status)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s: wrong response:\ngot  %s\nwant %s", s.URL, got, s.Response)
+		}
+	}
+}
+
+func TestSparseFieldsPage(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithSparseFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Recent?fields=name", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	want := `{"success":true,"data":[{"Name":"Alice"},{"Name":"Bob"}],"meta":{"page":1,"per_page":20,"total":50}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("Wrong response:\ngot  %s\nwant %s", got, want)
+	}
+}
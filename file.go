@@ -0,0 +1,126 @@
+package servicehandler
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// File is a download returned by a service method. It is served with
+// http.ServeContent so clients get Range requests, If-Modified-Since and the
+// content type from the Name (or the content itself).
+type File struct {
+	Content     io.ReadSeeker // Closed after sending if it is an io.Closer
+	Name        string
+	ModTime     time.Time
+	ContentType string // Optional
+	Inline      bool   // Display in the browser instead of downloading
+
+	// Set if Content is already compressed (i.e. "gzip") so it isn't again
+	ContentEncoding string
+}
+
+// Services can also return an io.Reader or []byte which are sent as-is
+type downloadKind int
+
+const (
+	notDownload downloadKind = iota
+	fileDownload
+	readerDownload
+	bytesDownload
+)
+
+var (
+	fileType   = reflect.TypeOf(File{})
+	readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	bytesType  = reflect.TypeOf([]byte(nil))
+)
+
+func downloadType(t reflect.Type) downloadKind {
+	switch {
+	case t == fileType || t == reflect.PtrTo(fileType):
+		return fileDownload
+	case t == bytesType:
+		return bytesDownload
+	case t.Implements(readerType):
+		return readerDownload
+	}
+	return notDownload
+}
+
+// download sends the File, io.Reader or []byte returned by a service method
+func (c *config) download(w http.ResponseWriter, r *http.Request, method *serviceMethod, result reflect.Value) {
+	if (result.Kind() == reflect.Ptr || result.Kind() == reflect.Interface ||
+		result.Kind() == reflect.Slice) && result.IsNil() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	startResponse(r)
+
+	w, done := c.compressDownload(w, r)
+	defer done()
+
+	switch method.download {
+	case fileDownload:
+		f := reflect.Indirect(result).Interface().(File)
+		serveFile(w, r, f)
+
+	case bytesDownload:
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(result.Bytes()))
+
+	case readerDownload:
+		reader := result.Interface().(io.Reader)
+		if closer, ok := reader.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		if seeker, ok := reader.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, "", time.Time{}, seeker)
+			return
+		}
+
+		// Can't seek so no Range support, just sniff the content type
+		br := bufio.NewReader(reader)
+		head, _ := br.Peek(512)
+		w.Header().Set("Content-Type", http.DetectContentType(head))
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, br)
+	}
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, f File) {
+	if closer, ok := f.Content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if f.Content == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	disposition := "attachment"
+	if f.Inline {
+		disposition = "inline"
+	}
+
+	if f.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": f.Name}))
+	} else {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+
+	if f.ContentType != "" {
+		w.Header().Set("Content-Type", f.ContentType)
+	}
+
+	if f.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", f.ContentEncoding)
+	}
+
+	http.ServeContent(w, r, f.Name, f.ModTime, f.Content)
+}
@@ -0,0 +1,116 @@
+package servicehandler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reportTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type FileService struct{}
+
+func (s *FileService) Report(ctx context.Context, params struct{}) (*File, error) {
+	return &File{
+		Content: strings.NewReader("hello world"),
+		Name:    "report.txt",
+		ModTime: reportTime,
+	}, nil
+}
+
+func (s *FileService) Bytes(ctx context.Context, params struct{}) ([]byte, error) {
+	return []byte("%PDF-1.4"), nil
+}
+
+func (s *FileService) Reader(ctx context.Context, params struct{}) (io.Reader, error) {
+	// Hide the io.Seeker of strings.Reader
+	return io.LimitReader(strings.NewReader("<html><body>hi</body></html>"), 100), nil
+}
+
+func TestDownloads(t *testing.T) {
+
+	mux, err := Wrap(&FileService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name        string
+		URL         string
+		Header      http.Header
+		StatusCode  int
+		ContentType string
+		Disposition string
+		Response    string
+	}{
+		{
+			Name:        "File",
+			URL:         "/Report",
+			StatusCode:  http.StatusOK,
+			ContentType: "text/plain; charset=utf-8",
+			Disposition: "attachment; filename=report.txt",
+			Response:    "hello world",
+		},
+		{
+			Name:        "File Range",
+			URL:         "/Report",
+			Header:      http.Header{"Range": {"bytes=0-4"}},
+			StatusCode:  http.StatusPartialContent,
+			ContentType: "text/plain; charset=utf-8",
+			Disposition: "attachment; filename=report.txt",
+			Response:    "hello",
+		},
+		{
+			Name:        "File Not Modified",
+			URL:         "/Report",
+			Header:      http.Header{"If-Modified-Since": {reportTime.Format(http.TimeFormat)}},
+			StatusCode:  http.StatusNotModified,
+			Disposition: "attachment; filename=report.txt",
+		},
+		{
+			Name:        "Bytes",
+			URL:         "/Bytes",
+			StatusCode:  http.StatusOK,
+			ContentType: "application/pdf",
+			Response:    "%PDF-1.4",
+		},
+		{
+			Name:        "Reader",
+			URL:         "/Reader",
+			StatusCode:  http.StatusOK,
+			ContentType: "text/html; charset=utf-8",
+			Response:    "<html><body>hi</body></html>",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", s.URL, nil)
+			for k, v := range s.Header {
+				req.Header[k] = v
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != s.StatusCode {
+				t.Errorf("Wrong status code: got %v want %v", rr.Code, s.StatusCode)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != s.ContentType {
+				t.Errorf("Wrong content type: got %q want %q", ct, s.ContentType)
+			}
+
+			if cd := rr.Header().Get("Content-Disposition"); cd != s.Disposition {
+				t.Errorf("Wrong content disposition: got %q want %q", cd, s.Disposition)
+			}
+
+			if got := rr.Body.String(); got != s.Response {
+				t.Errorf("Wrong response:\ngot %q\nwant %q", got, s.Response)
+			}
+		})
+	}
+}
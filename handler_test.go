@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -26,6 +27,9 @@ type TestUserService struct {
 // Test POST with JSON body
 func (s *TestUserService) Save(ctx context.Context, u *TestUser) (int, error) {
 	// fmt.Printf("Called Save with %v from %v\n", u, s)
+	if u.Email == "taken@example.com" {
+		return 0, FieldErrors{"Email": "is already registered"}
+	}
 	return 23, nil
 }
 
@@ -40,11 +44,41 @@ func (s *TestUserService) Get(ctx context.Context, params struct {
 
 // Test GET with multiple params for loading
 func (s *TestUserService) Recent(ctx context.Context, params struct {
-	Page    int
-	PerPage int
-}) ([]*TestUser, error) {
+	PageParams
+}) (Page[*TestUser], error) {
 	// fmt.Printf("Called Recent with %v from %v\n", params.Page, params.PerPage)
-	return []*TestUser{&TestUser{Name: "Alice"}, &TestUser{Name: "Bob"}}, nil
+	return Page[*TestUser]{
+		Items: []*TestUser{&TestUser{Name: "Alice"}, &TestUser{Name: "Bob"}},
+		Total: 50,
+	}, nil
+}
+
+// Test GET with an error-only method
+func (s *TestUserService) Remove(ctx context.Context, params struct {
+	ID int `valid:"required"`
+}) error {
+	if params.ID == 1 {
+		return &StatusError{Status: http.StatusLocked, Message: "User is locked"}
+	}
+	return nil
+}
+
+type TestRange struct {
+	Start int `valid:"required"`
+	End   int
+}
+
+// Business rule the struct tags can't express
+func (r *TestRange) Validate(ctx context.Context) error {
+	if r.End < r.Start {
+		return FieldErrors{"End": "must be after Start"}
+	}
+	return nil
+}
+
+// Test POST with a ParamValidator
+func (s *TestUserService) Count(ctx context.Context, r *TestRange) (int, error) {
+	return r.End - r.Start, nil
 }
 
 // type sample struct {
@@ -79,6 +113,13 @@ func TestValidation(t *testing.T) {
 			StatusCode: http.StatusOK,
 			// Response:   "foo",
 		},
+		{
+			Name:       "Service Field Error",
+			URL:        "/Save",
+			JSON:       map[string]string{"name": "john", "email": "taken@example.com"},
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"success":false,"error":"Invalid Request","fields":{"Email":"is already registered"}}`,
+		},
 		{
 			Name:       "Valid Query Parameter",
 			URL:        "/Get?ID=34",
@@ -91,6 +132,27 @@ func TestValidation(t *testing.T) {
 			JSON:       nil,
 			StatusCode: http.StatusBadRequest,
 		},
+		{
+			Name:       "Valid Range",
+			URL:        "/Count",
+			JSON:       map[string]int{"start": 1, "end": 5},
+			StatusCode: http.StatusOK,
+			Response:   `{"success":true,"data":4}`,
+		},
+		{
+			Name:       "Invalid Range",
+			URL:        "/Count",
+			JSON:       map[string]int{"start": 5, "end": 1},
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"success":false,"error":"Invalid Request","fields":{"End":"must be after Start"}}`,
+		},
+		{
+			Name:       "Invalid Range and Start",
+			URL:        "/Count",
+			JSON:       map[string]int{"end": -1},
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"success":false,"error":"Invalid Request","fields":{"End":"must be after Start","Start":"non zero value required"}}`,
+		},
 		{
 			Name: "Valid Query Parameters",
 			URL:  "/Recent?Page=1&PerPage=23",
@@ -200,3 +262,220 @@ func BenchmarkHandler(b *testing.B) {
 		}
 	}
 }
+
+func TestCustomValidator(t *testing.T) {
+
+	validator := ValidatorFunc(func(i interface{}) FieldErrors {
+		if u, ok := i.(*TestUser); ok && u.Name == "admin" {
+			return FieldErrors{"Name": "is reserved"}
+		}
+		return nil
+	})
+
+	mux, err := Wrap(&TestUserService{}, WithValidator(validator))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/Save", strings.NewReader(`{"name":"admin"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	response := strings.TrimSpace(rr.Body.String())
+	want := `{"success":false,"error":"Invalid Request","fields":{"Name":"is reserved"}}`
+	if response != want {
+		t.Errorf("Wrong response:\ngot %s\nwant %s", response, want)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithProblemDetails())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name       string
+		Method     string
+		URL        string
+		Body       string
+		StatusCode int
+		Response   string
+	}{
+		{
+			Name:       "Not Found",
+			Method:     "GET",
+			URL:        "/Missing",
+			StatusCode: http.StatusNotFound,
+			Response:   `{"type":"about:blank","title":"Not Found","status":404,"instance":"/Missing"}`,
+		},
+		{
+			Name:       "Method Not Allowed",
+			Method:     "POST",
+			URL:        "/Get",
+			Body:       `{}`,
+			StatusCode: http.StatusMethodNotAllowed,
+			Response:   `{"type":"about:blank","title":"Method Not Allowed","status":405,"instance":"/Get"}`,
+		},
+		{
+			Name:       "Validation",
+			Method:     "POST",
+			URL:        "/Save",
+			Body:       `{"name":"john","email":"a@b"}`,
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Invalid Request","instance":"/Save","errors":{"Email":"a@b does not validate as email"}}`,
+		},
+		{
+			Name:       "Service Error",
+			Method:     "GET",
+			URL:        "/Remove?ID=1",
+			StatusCode: http.StatusLocked,
+			Response:   `{"type":"about:blank","title":"Locked","status":423,"detail":"User is locked","instance":"/Remove"}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			req := httptest.NewRequest(s.Method, s.URL, strings.NewReader(s.Body))
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != s.StatusCode {
+				t.Errorf("%s returned wrong status code: got %v want %v", s.URL, rr.Code, s.StatusCode)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("%s returned wrong content type: %s", s.URL, ct)
+			}
+
+			response := strings.TrimSpace(rr.Body.String())
+			if response != s.Response {
+				t.Errorf("%s returned wrong response:\ngot %s\nwant %s", s.URL, response, s.Response)
+			}
+		})
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+
+	custom := func(r *http.Request, result interface{}, err *ResponseError) interface{} {
+		meta := map[string]string{"method": filepath.Base(r.URL.Path)}
+		if err != nil {
+			return JSONResponse{Error: err.Error(), Meta: meta}
+		}
+		return JSONResponse{Success: true, Data: result, Meta: meta}
+	}
+
+	scenarios := []struct {
+		Name       string
+		Option     Option
+		URL        string
+		StatusCode int
+		Response   string
+	}{
+		{
+			Name:       "Default",
+			URL:        "/Get?ID=1",
+			StatusCode: http.StatusOK,
+			Response:   `{"success":true,"data":{"Name":"John","Email":""}}`,
+		},
+		{
+			Name:       "Default Not Found",
+			URL:        "/Missing",
+			StatusCode: http.StatusNotFound,
+			Response:   `{"success":false,"error":"Not Found"}`,
+		},
+		{
+			Name:       "Default No Content",
+			URL:        "/Remove?ID=2",
+			StatusCode: http.StatusOK,
+			Response:   `{"success":true}`,
+		},
+		{
+			Name:       "Raw No Content",
+			Option:     WithRawResponses(),
+			URL:        "/Remove?ID=2",
+			StatusCode: http.StatusNoContent,
+			Response:   ``,
+		},
+		{
+			Name:       "Raw",
+			Option:     WithRawResponses(),
+			URL:        "/Get?ID=1",
+			StatusCode: http.StatusOK,
+			Response:   `{"Name":"John","Email":""}`,
+		},
+		{
+			Name:       "Raw Validation",
+			Option:     WithRawResponses(),
+			URL:        "/Get",
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"success":false,"error":"Invalid Request","fields":{"ID":"non zero value required"}}`,
+		},
+		{
+			Name:       "Custom",
+			Option:     WithEnvelope(custom),
+			URL:        "/Get?ID=1",
+			StatusCode: http.StatusOK,
+			Response:   `{"success":true,"data":{"Name":"John","Email":""},"meta":{"method":"Get"}}`,
+		},
+		{
+			Name:       "Custom Not Found",
+			Option:     WithEnvelope(custom),
+			URL:        "/Missing",
+			StatusCode: http.StatusNotFound,
+			Response:   `{"success":false,"error":"Not Found","meta":{"method":"Missing"}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			var options []Option
+			if s.Option != nil {
+				options = append(options, s.Option)
+			}
+
+			mux, err := Wrap(&TestUserService{}, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", s.URL, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != s.StatusCode {
+				t.Errorf("%s returned wrong status code: got %v want %v", s.URL, rr.Code, s.StatusCode)
+			}
+
+			response := strings.TrimSpace(rr.Body.String())
+			if response != s.Response {
+				t.Errorf("%s returned wrong response:\ngot %s\nwant %s", s.URL, response, s.Response)
+			}
+		})
+	}
+}
+
+func TestJSONHelper(t *testing.T) {
+
+	// The status is left to the caller
+	rr := httptest.NewRecorder()
+	rr.WriteHeader(http.StatusBadRequest)
+	JSON(rr, JSONResponse{Error: "bad"})
+
+	if rr.Code != http.StatusBadRequest || strings.TrimSpace(rr.Body.String()) != `{"success":false,"error":"bad"}` {
+		t.Errorf("Wrong response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	JSON(rr, true)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Wrong response %d: %v", rr.Code, rr.Header())
+	}
+}
@@ -0,0 +1,254 @@
+package servicehandler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Messages bundled with every Catalog. The keys are validator rule names,
+// "Field.rule" to override a single field, or "invalid_request" for the
+// summary in JSONResponse.Error. "{field}" is replaced with the field name.
+var englishMessages = map[string]string{
+	"invalid_request": "Invalid Request",
+	"invalid":         "{field} is invalid",
+	"required":        "{field} is required",
+	"email":           "{field} must be a valid email address",
+	"url":             "{field} must be a valid URL",
+	"alpha":           "{field} may only contain letters",
+	"alphanum":        "{field} may only contain letters and numbers",
+	"numeric":         "{field} may only contain numbers",
+	"int":             "{field} must be a whole number",
+	"float":           "{field} must be a number",
+	"ascii":           "{field} may only contain ASCII characters",
+	"uuid":            "{field} must be a valid UUID",
+	"length":          "{field} has the wrong length",
+	"stringlength":    "{field} has the wrong length",
+	"range":           "{field} is out of range",
+	"in":              "{field} is not one of the allowed values",
+	"matches":         "{field} has the wrong format",
+}
+
+// Catalog of translated validation messages
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]string // language => key => message
+}
+
+// NewCatalog with the bundled English messages as the fallback language
+func NewCatalog() *Catalog {
+	c := &Catalog{
+		fallback: "en",
+		messages: make(map[string]map[string]string),
+	}
+	c.Add("en", englishMessages)
+	return c
+}
+
+// Add (or replace) messages for a language such as "fr" or "pt-BR"
+func (c *Catalog) Add(lang string, messages map[string]string) {
+	lang = strings.ToLower(lang)
+	if c.messages[lang] == nil {
+		c.messages[lang] = make(map[string]string, len(messages))
+	}
+	for k, v := range messages {
+		c.messages[lang][k] = v
+	}
+}
+
+// Load a JSON object of key => message for the given language
+func (c *Catalog) Load(lang string, r io.Reader) error {
+	var messages map[string]string
+	if err := json.NewDecoder(r).Decode(&messages); err != nil {
+		return err
+	}
+	c.Add(lang, messages)
+	return nil
+}
+
+// LoadFS loads every "<lang>.json" file in the root of fsys
+func (c *Catalog) LoadFS(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+
+		err = c.Load(strings.TrimSuffix(path.Base(name), ".json"), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Has reports if the catalog contains any messages for lang
+func (c *Catalog) Has(lang string) bool {
+	_, ok := c.messages[strings.ToLower(lang)]
+	return ok
+}
+
+// Message for the rule of the field in lang. Regional languages ("fr-CA")
+// fall back to the base language ("fr") and then to English.
+func (c *Catalog) Message(lang, rule, field string) (string, bool) {
+	for _, l := range c.candidates(lang) {
+		messages := c.messages[l]
+		if m, ok := messages[field+"."+rule]; ok {
+			return strings.ReplaceAll(m, "{field}", field), true
+		}
+		if m, ok := messages[rule]; ok {
+			return strings.ReplaceAll(m, "{field}", field), true
+		}
+	}
+	return "", false
+}
+
+func (c *Catalog) candidates(lang string) []string {
+	lang = strings.ToLower(lang)
+	langs := []string{lang}
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		langs = append(langs, lang[:i])
+	}
+	return append(langs, c.fallback)
+}
+
+// RuleError is a single failed validation rule such as "email" or "required"
+type RuleError struct {
+	Field   string
+	Rule    string
+	Message string // Untranslated message from the validator
+}
+
+// RuleValidator is a Validator that can report which rule failed on each
+// field so the message can be translated and given a stable code
+type RuleValidator interface {
+	Validator
+	ValidateRules(i interface{}) []RuleError
+}
+
+type localeKey struct{}
+
+// WithLocale sets the language for messages, taking precedence over the
+// Accept-Language header (i.e. from a user's saved preference)
+func WithLocale(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, localeKey{}, lang)
+}
+
+// Locale chosen for the request, if any
+func Locale(ctx context.Context) string {
+	lang, _ := ctx.Value(localeKey{}).(string)
+	return lang
+}
+
+// requestLocale picks the language from the context or the first language in
+// the Accept-Language header that the catalog knows about
+func requestLocale(r *http.Request, c *Catalog) string {
+	if lang := Locale(r.Context()); lang != "" {
+		return lang
+	}
+
+	for _, lang := range acceptLanguages(r.Header.Get("Accept-Language")) {
+		for _, l := range c.candidates(lang) {
+			if c.Has(l) {
+				return l
+			}
+		}
+	}
+
+	return c.fallback
+}
+
+// acceptLanguages parses "fr-CH, fr;q=0.9, en;q=0.8" ordered by quality
+func acceptLanguages(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	var langs []language
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		if q > 0 {
+			langs = append(langs, language{tag, q})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// localize runs the validator reporting rules and translates each message
+// using the client's name for the field. The codes are the rule names.
+func localize(lang string, c *Catalog, v RuleValidator, i interface{}, names map[string]string) (errs FieldErrors, codes map[string]string) {
+	for _, e := range v.ValidateRules(i) {
+		if errs == nil {
+			errs = make(FieldErrors)
+			codes = make(map[string]string)
+		}
+
+		// Only report the first failed rule of each field
+		if _, ok := errs[e.Field]; ok {
+			continue
+		}
+
+		name := e.Field
+		if n, ok := names[name]; ok {
+			name = n
+		}
+
+		message, ok := c.Message(lang, e.Rule, name)
+		if !ok {
+			message = e.Message
+		}
+
+		errs[e.Field] = message
+		codes[e.Field] = e.Rule
+	}
+
+	return errs, codes
+}
+
+// invalidRequest is the summary message for a request with invalid fields
+func (c *config) invalidRequest(r *http.Request) string {
+	if c.catalog != nil {
+		if m, ok := c.catalog.Message(requestLocale(r, c.catalog), "invalid_request", ""); ok {
+			return m
+		}
+	}
+	return "Invalid Request"
+}
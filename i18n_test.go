@@ -0,0 +1,68 @@
+package servicehandler
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAcceptLanguages(t *testing.T) {
+	got := acceptLanguages("fr-CH, fr;q=0.9, *;q=0.5, en;q=0.8, de;q=0")
+	want := []string{"fr-CH", "fr", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestLocalizedValidation(t *testing.T) {
+
+	catalog := NewCatalog()
+	err := catalog.Load("fr", strings.NewReader(`{
+		"invalid_request": "Requête invalide",
+		"email": "{field} doit être une adresse e-mail valide"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux, err := Wrap(&TestUserService{}, WithCatalog(catalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name     string
+		Language string
+		Response string
+	}{
+		{
+			Name:     "English",
+			Response: `{"success":false,"error":"Invalid Request","fields":{"Email":"Email must be a valid email address"},"codes":{"Email":"email"}}`,
+		},
+		{
+			Name:     "French",
+			Language: "fr-CA,en;q=0.5",
+			Response: `{"success":false,"error":"Requête invalide","fields":{"Email":"Email doit être une adresse e-mail valide"},"codes":{"Email":"email"}}`,
+		},
+		{
+			Name:     "Unknown",
+			Language: "de",
+			Response: `{"success":false,"error":"Invalid Request","fields":{"Email":"Email must be a valid email address"},"codes":{"Email":"email"}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/Save", strings.NewReader(`{"name":"john","email":"a@b"}`))
+			req.Header.Set("Accept-Language", s.Language)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			response := strings.TrimSpace(rr.Body.String())
+			if response != s.Response {
+				t.Errorf("Wrong response:\ngot %s\nwant %s", response, s.Response)
+			}
+		})
+	}
+}
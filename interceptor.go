@@ -0,0 +1,50 @@
+package servicehandler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Invoker calls the next Interceptor or, at the end of the chain, the service
+// method itself. Error-only methods return a nil result.
+type Invoker func(ctx context.Context, params interface{}) (interface{}, error)
+
+// Interceptor runs after the params are decoded and validated and around the
+// call to the service method. It can return without calling next, change the
+// params or result, or return an error which is handled like one from the
+// method (FieldErrors give a 400, etc.).
+type Interceptor func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error)
+
+// invoker calls the method of the service with the given params
+func (m *serviceMethod) invoker(service reflect.Value) Invoker {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		value := reflect.ValueOf(params)
+		if !value.IsValid() || value.Type() != m.in[2] {
+			return nil, fmt.Errorf("%s called with %T params instead of %s", m.name, params, m.in[2])
+		}
+
+		out := m.method.Call([]reflect.Value{service, reflect.ValueOf(ctx), value})
+
+		// func (...) error or func (...) (interface{}, error)
+		var err error
+		if len(out) > 0 {
+			err, _ = out[len(out)-1].Interface().(error)
+		}
+		if len(out) < 2 {
+			return nil, err
+		}
+		return out[0].Interface(), err
+	}
+}
+
+// intercept wraps the invoker with the interceptors, the first is outermost
+func intercept(invoke Invoker, info MethodInfo, interceptors []Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoke
+		invoke = func(ctx context.Context, params interface{}) (interface{}, error) {
+			return interceptor(ctx, info, params, next)
+		}
+	}
+	return invoke
+}
@@ -0,0 +1,139 @@
+package servicehandler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			calls = append(calls, name+" "+info.Service+"."+info.Name)
+			return next(ctx, params)
+		}
+	}
+
+	mux, err := Wrap(&TestUserService{},
+		WithInterceptor(trace("outer")),
+		WithInterceptor(trace("inner")),
+		Method("Get", WithInterceptor(func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			// Short-circuit with a cached user
+			return &TestUser{Name: "Cached"}, nil
+		})),
+		Method("Count", WithInterceptor(func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			// Change the params and the result
+			r := params.(*TestRange)
+			r.End *= 2
+			result, err := next(ctx, r)
+			return result.(int) + 1, err
+		})),
+		Method("Save", WithInterceptor(func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			if params.(*TestUser).Name == "root" {
+				return nil, FieldErrors{"Name": "is reserved"}
+			}
+			return next(ctx, params)
+		})),
+		Method("Remove", WithInterceptor(func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			return next(ctx, struct{}{})
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Method   string
+		URL      string
+		Body     string
+		Status   int
+		Response string
+		Calls    int
+	}{
+		{
+			Method:   "GET",
+			URL:      "/Get?ID=1",
+			Status:   200,
+			Response: `{"success":true,"data":{"Name":"Cached","Email":""}}`,
+			Calls:    2,
+		},
+		{
+			Method:   "POST",
+			URL:      "/Count",
+			Body:     `{"Start":2,"End":5}`,
+			Status:   200,
+			Response: `{"success":true,"data":9}`,
+			Calls:    2,
+		},
+		{
+			Method:   "POST",
+			URL:      "/Save",
+			Body:     `{"Name":"root","Email":"root@example.com"}`,
+			Status:   400,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"Name":"is reserved"}}`,
+			Calls:    2,
+		},
+		{
+			// Validation errors stop the request before the interceptors
+			Method:   "GET",
+			URL:      "/Get",
+			Status:   400,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"ID":"non zero value required"}}`,
+		},
+		{
+			// Params of the wrong type are an internal error
+			Method: "GET",
+			URL:    "/Remove?ID=2",
+			Status: 500,
+			Calls:  2,
+		},
+	}
+
+	for _, s := range scenarios {
+		calls = nil
+		req := httptest.NewRequest(s.Method, s.URL, strings.NewReader(s.Body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != s.Status {
+			t.Errorf("%s: wrong status: got %d want %d: %s", s.URL, rr.Code, s.Status, rr.Body.String())
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); s.Response != "" && got != s.Response {
+			t.Errorf("%s: wrong response:\ngot  %s\nwant %s", s.URL, got, s.Response)
+		}
+
+		if len(calls) != s.Calls || (s.Calls > 0 && calls[0] != "outer TestUserService."+s.URL[1:strings.IndexAny(s.URL+"?", "?")]) {
+			t.Errorf("%s: wrong interceptor calls: %v", s.URL, calls)
+		}
+	}
+}
+
+func TestInterceptorErrors(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithErrorLog(log.New(io.Discard, "", 0)),
+		WithInterceptor(func(ctx context.Context, info MethodInfo, params interface{}, next Invoker) (interface{}, error) {
+			result, err := next(ctx, params)
+			if err == nil {
+				err = errors.New("audit log unavailable")
+			}
+			return result, err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Get?ID=1", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 500 || strings.Contains(rr.Body.String(), "audit") {
+		t.Errorf("Wrong response: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package servicehandler
+
+import (
+	"net/http"
+	"sort"
+)
+
+// MethodInfo describes a wrapped service method for Interceptors, Authorizers
+// and Methods
+type MethodInfo struct {
+	Service string   // Type name of the wrapped service
+	Name    string   // Method name, also the last part of the URL path
+	Roles   []string // Required roles set with WithRoles
+	Scopes  []string // Required scopes set with WithScopes
+
+	ErrorCodes []ErrorCode // Declared with WithErrorCodes
+}
+
+// wrappedHandler is the http.Handler returned by Wrap
+type wrappedHandler struct {
+	http.HandlerFunc
+	methods []MethodInfo
+}
+
+func newWrappedHandler(serve http.HandlerFunc, methods map[string]*serviceMethod) *wrappedHandler {
+	h := &wrappedHandler{HandlerFunc: serve}
+	for _, m := range methods {
+		h.methods = append(h.methods, m.info)
+	}
+	sort.Slice(h.methods, func(i, j int) bool {
+		return h.methods[i].Name < h.methods[j].Name
+	})
+	return h
+}
+
+// Methods of a handler returned by Wrap sorted by name, i.e. to publish the
+// error codes each method can return to frontends. Other handlers have none.
+func Methods(h http.Handler) []MethodInfo {
+	if wh, ok := h.(*wrappedHandler); ok {
+		methods := make([]MethodInfo, len(wh.methods))
+		for i, m := range wh.methods {
+			methods[i] = m.copy()
+		}
+		return methods
+	}
+	return nil
+}
+
+// copy of the info that doesn't share the slices used by the handler
+func (m MethodInfo) copy() MethodInfo {
+	m.Roles = append([]string(nil), m.Roles...)
+	m.Scopes = append([]string(nil), m.Scopes...)
+	m.ErrorCodes = append([]ErrorCode(nil), m.ErrorCodes...)
+	return m
+}
@@ -0,0 +1,210 @@
+package servicehandler
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy decides the name clients use for a parameter field
+type NamingStrategy int
+
+const (
+	// NameGo uses the Go field name as-is (the default)
+	NameGo NamingStrategy = iota
+	// NameCamelCase turns "PerPage" into "perPage"
+	NameCamelCase
+	// NameSnakeCase turns "PerPage" into "per_page"
+	NameSnakeCase
+	// NameJSONTag follows the `json` tag, falling back to the Go field name
+	NameJSONTag
+)
+
+// Name of the struct field as seen by the client
+func (n NamingStrategy) Name(field reflect.StructField) string {
+	switch n {
+	case NameCamelCase:
+		words := splitWords(field.Name)
+		for i, w := range words {
+			w = strings.ToLower(w)
+			if i > 0 {
+				w = strings.ToUpper(w[:1]) + w[1:]
+			}
+			words[i] = w
+		}
+		return strings.Join(words, "")
+	case NameSnakeCase:
+		return strings.ToLower(strings.Join(splitWords(field.Name), "_"))
+	case NameJSONTag:
+		if name := jsonName(field); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// paramField is a precomputed public field of a parameter struct
+type paramField struct {
+	index []int  // For reflect.Value.FieldByIndex
+	name  string // Name under the NamingStrategy
+	query string // Query string key
+	field reflect.StructField
+}
+
+// paramFields computes the client facing names of each public field once so
+// requests don't have to. Fields of embedded structs (like PageParams) are
+// treated as fields of the parent.
+func paramFields(t reflect.Type, naming NamingStrategy) []paramField {
+	return appendParamFields(nil, nil, t, naming)
+}
+
+func appendParamFields(fields []paramField, index []int, t reflect.Type, naming NamingStrategy) []paramField {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// Private fields can't be set (or validated)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		path := append(index[:len(index):len(index)], i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = appendParamFields(fields, path, field.Type, naming)
+			continue
+		}
+
+		name := naming.Name(field)
+
+		query := name
+		if tag, ok := field.Tag.Lookup(TagQuery); ok {
+			query = tag
+		}
+
+		fields = append(fields, paramField{
+			index: path,
+			name:  name,
+			query: query,
+			field: field,
+		})
+	}
+
+	return fields
+}
+
+// errorNames maps the names govalidator reports (the Go field name or json
+// tag) to the name chosen by the NamingStrategy
+func errorNames(fields []paramField) map[string]string {
+	names := make(map[string]string, len(fields)*2)
+	for _, f := range fields {
+		names[f.field.Name] = f.name
+		if name := jsonName(f.field); name != "" {
+			names[name] = f.name
+		}
+	}
+	return names
+}
+
+// renameFields rewrites the keys of validation errors to the client's names
+func renameFields(errs FieldErrors, names map[string]string) FieldErrors {
+	if errs == nil {
+		return nil
+	}
+
+	renamed := make(FieldErrors, len(errs))
+	for k, v := range errs {
+		if name, ok := names[k]; ok {
+			k = name
+		}
+		renamed[k] = v
+	}
+	return renamed
+}
+
+// queryGetter returns a lookup for url.Values that can ignore key case
+func queryGetter(values url.Values, ignoreCase bool) func(string) string {
+	if !ignoreCase {
+		return values.Get
+	}
+
+	lower := make(map[string]string, len(values))
+	for k, v := range values {
+		k = strings.ToLower(k)
+		if _, ok := lower[k]; ok || len(v) == 0 {
+			continue
+		}
+		lower[k] = v[0]
+	}
+
+	return func(key string) string {
+		return lower[strings.ToLower(key)]
+	}
+}
+
+// decodeBody fills object from a JSON body. encoding/json already matches
+// json tags and Go names (case-insensitive) so it is only bypassed for the
+// camelCase and snake_case strategies. Nested values are decoded as usual.
+func decodeBody(r io.Reader, object reflect.Value, fields []paramField, naming NamingStrategy) error {
+	if naming != NameCamelCase && naming != NameSnakeCase {
+		oi := object.Interface()
+		return json.NewDecoder(r).Decode(&oi)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return err
+	}
+
+	elem := reflect.Indirect(object)
+	for _, f := range fields {
+		b, ok := raw[f.name]
+		if !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(b, elem.FieldByIndex(f.index).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if i := strings.Index(tag, ","); i != -1 {
+		tag = tag[:i]
+	}
+	if tag == "-" {
+		return ""
+	}
+	return tag
+}
+
+// splitWords breaks a Go identifier into words: "UserID" -> "User", "ID" and
+// "HTTPServer2" -> "HTTP", "Server2"
+func splitWords(s string) []string {
+	runes := []rune(s)
+
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+
+		// "userName" and "HTTPServer" (the "S" starts a new word)
+		if unicode.IsUpper(cur) && (!unicode.IsUpper(prev) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	return append(words, string(runes[start:]))
+}
@@ -0,0 +1,105 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamingStrategy(t *testing.T) {
+
+	type sample struct {
+		PerPage  int
+		UserID   int
+		HTTPHost string
+		Email    string `json:"mail"`
+	}
+
+	scenarios := []struct {
+		Naming NamingStrategy
+		Want   []string
+	}{
+		{NameGo, []string{"PerPage", "UserID", "HTTPHost", "Email"}},
+		{NameCamelCase, []string{"perPage", "userId", "httpHost", "email"}},
+		{NameSnakeCase, []string{"per_page", "user_id", "http_host", "email"}},
+		{NameJSONTag, []string{"PerPage", "UserID", "HTTPHost", "mail"}},
+	}
+
+	typ := reflect.TypeOf(sample{})
+	for _, s := range scenarios {
+		for i, want := range s.Want {
+			if got := s.Naming.Name(typ.Field(i)); got != want {
+				t.Errorf("%d: wrong name for %s: got %q want %q", s.Naming, typ.Field(i).Name, got, want)
+			}
+		}
+	}
+}
+
+type NamingService struct{}
+
+type NamingUser struct {
+	FirstName string `valid:"required"`
+	Email     string `valid:"email,required"`
+}
+
+func (s *NamingService) Save(ctx context.Context, u *NamingUser) (string, error) {
+	return u.FirstName, nil
+}
+
+func (s *NamingService) Recent(ctx context.Context, params struct {
+	PerPage int `valid:"required"`
+}) (int, error) {
+	return params.PerPage, nil
+}
+
+func TestSnakeCaseNaming(t *testing.T) {
+
+	mux, err := Wrap(&NamingService{}, WithNaming(NameSnakeCase), WithCaseInsensitiveQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name     string
+		Method   string
+		URL      string
+		Body     string
+		Response string
+	}{
+		{
+			Name:     "Query",
+			Method:   "GET",
+			URL:      "/Recent?Per_Page=5",
+			Response: `{"success":true,"data":5}`,
+		},
+		{
+			Name:     "Body",
+			Method:   "POST",
+			URL:      "/Save",
+			Body:     `{"first_name":"john","email":"j@example.com"}`,
+			Response: `{"success":true,"data":"john"}`,
+		},
+		{
+			Name:     "Error Fields",
+			Method:   "POST",
+			URL:      "/Save",
+			Body:     `{"first_name":"john","email":"a@b"}`,
+			Response: `{"success":false,"error":"Invalid Request","fields":{"email":"a@b does not validate as email"}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			req := httptest.NewRequest(s.Method, s.URL, strings.NewReader(s.Body))
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			response := strings.TrimSpace(rr.Body.String())
+			if response != s.Response {
+				t.Errorf("%s returned wrong response:\ngot %s\nwant %s", s.URL, response, s.Response)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package servicehandler
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Option configures the http.Handler returned by Wrap
+type Option func(*config)
+
+// Settings for the methods of a wrapped service
+type config struct {
+	naming          NamingStrategy
+	queryIgnoreCase bool
+	validator       Validator
+	catalog         *Catalog
+	problems        bool
+	raw             bool
+	envelope        Envelope
+	logger          *log.Logger
+	errorCodes      []ErrorCode
+	perPage         int
+	maxPerPage      int
+	cursorSecret    []byte
+	etags           bool
+	cacheControl    string
+	compression     *compression
+	sparseFields    bool
+	interceptors    []Interceptor
+	authorizer      Authorizer
+	contexts        []ContextFunc
+	devMode         bool
+	timeout         time.Duration
+	rateLimit       int
+	ratePer         time.Duration
+	rateKey         RateLimitKey
+	roles           []string
+	scopes          []string
+	methods         map[string][]Option // Options for a single method
+}
+
+func newConfig(options []Option) *config {
+	c := &config{
+		validator:  GoValidator{},
+		logger:     log.New(os.Stderr, "", log.LstdFlags),
+		perPage:    DefaultPerPage,
+		maxPerPage: MaxPerPage,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// method returns a copy of the config with the options set for just that
+// method applied on top
+func (c *config) method(name string) *config {
+	mc := *c
+	for _, option := range c.methods[name] {
+		option(&mc)
+	}
+	return &mc
+}
+
+// Method applies options to only the named service method. Wrap fails if the
+// service has no such method.
+func Method(name string, options ...Option) Option {
+	return func(c *config) {
+		methods := make(map[string][]Option, len(c.methods)+1)
+		for k, v := range c.methods {
+			methods[k] = v
+		}
+		methods[name] = append(methods[name][:len(methods[name]):len(methods[name])], options...)
+		c.methods = methods
+	}
+}
+
+// WithNaming sets how parameter fields are named in query strings, JSON
+// request bodies and the validation error fields sent back to the client
+func WithNaming(n NamingStrategy) Option {
+	return func(c *config) {
+		c.naming = n
+	}
+}
+
+// WithCaseInsensitiveQuery matches query parameter keys regardless of case so
+// "?perpage=10" and "?PerPage=10" fill the same field
+func WithCaseInsensitiveQuery() Option {
+	return func(c *config) {
+		c.queryIgnoreCase = true
+	}
+}
+
+// WithValidator replaces govalidator with another rule engine
+func WithValidator(v Validator) Option {
+	return func(c *config) {
+		c.validator = v
+	}
+}
+
+// WithCatalog translates validation messages into the language chosen by
+// WithLocale or the Accept-Language header and adds the failed rule of each
+// field to JSONResponse.Codes. The Validator must be a RuleValidator.
+func WithCatalog(catalog *Catalog) Option {
+	return func(c *config) {
+		c.catalog = catalog
+	}
+}
+
+// WithProblemDetails sends failures (validation, unknown methods, wrong HTTP
+// methods and service errors) as RFC 7807 application/problem+json documents
+// instead of a JSONResponse
+func WithProblemDetails() Option {
+	return func(c *config) {
+		c.problems = true
+	}
+}
+
+// WithRawResponses sends service results without the JSONResponse envelope.
+// Errors are still sent as a JSONResponse (or Problem).
+func WithRawResponses() Option {
+	return func(c *config) {
+		c.raw = true
+	}
+}
+
+// WithEnvelope builds every response body (results and errors) with e
+func WithEnvelope(e Envelope) Option {
+	return func(c *config) {
+		c.envelope = e
+	}
+}
+
+// WithErrorLog sets the logger for internal errors. Only the error ID is sent
+// to the client so this is the only place to find out what went wrong.
+func WithErrorLog(l *log.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithErrorCodes documents the error codes a method can return. Errors with a
+// registered code but no HTTPStatus() are sent with the registered status and
+// undeclared codes are logged so the registry stays complete. Methods returns
+// the codes of each method.
+func WithErrorCodes(codes ...ErrorCode) Option {
+	return func(c *config) {
+		c.errorCodes = append(c.errorCodes[:len(c.errorCodes):len(c.errorCodes)], codes...)
+	}
+}
+
+// WithPageLimits sets the default and maximum PageParams.PerPage. Wrap fails
+// unless 1 <= perPage <= max.
+func WithPageLimits(perPage, max int) Option {
+	return func(c *config) {
+		c.perPage = perPage
+		c.maxPerPage = max
+	}
+}
+
+// WithCursorSecret signs the NextCursor of each Page with HMAC-SHA256 so the
+// client gets an opaque token it can't tamper with. The service still sees
+// the plain value in PageParams.Cursor.
+func WithCursorSecret(secret []byte) Option {
+	return func(c *config) {
+		c.cursorSecret = secret
+	}
+}
+
+// WithETags adds a strong ETag (a hash of the response or the result's own
+// ETag() string) to GET responses and answers If-None-Match with 304 Not
+// Modified. Results implementing LastModified() time.Time also get a
+// Last-Modified header and If-Modified-Since support.
+func WithETags() Option {
+	return func(c *config) {
+		c.etags = true
+	}
+}
+
+// WithCacheControl sets the Cache-Control header of successful GET responses.
+// Use with Method() as each method has different needs.
+func WithCacheControl(value string) Option {
+	return func(c *config) {
+		c.cacheControl = value
+	}
+}
+
+// WithCompression gzip or deflate compresses responses of at least minSize
+// bytes when the client accepts it. Only the given content types (or the
+// DefaultCompressionTypes) are compressed. Streams, byte ranges and files
+// with a ContentEncoding are never compressed.
+func WithCompression(minSize int, types ...string) Option {
+	if len(types) == 0 {
+		types = DefaultCompressionTypes
+	}
+	return func(c *config) {
+		c.compression = &compression{minSize: minSize, types: types}
+	}
+}
+
+// WithSparseFields lets clients pick the fields of the result they want with
+// ?fields=name,email. Nested fields use dots ("author.name") and apply to
+// every element of a slice. Unknown fields are rejected with a 400.
+func WithSparseFields() Option {
+	return func(c *config) {
+		c.sparseFields = true
+	}
+}
+
+// WithInterceptor adds an Interceptor around the service methods. They run in
+// the order added with those given to Method after the global ones.
+func WithInterceptor(i Interceptor) Option {
+	return func(c *config) {
+		c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], i)
+	}
+}
+
+// WithAuthorizer checks every call with the Authorizer before it is made
+func WithAuthorizer(a Authorizer) Option {
+	return func(c *config) {
+		c.authorizer = a
+	}
+}
+
+// WithRoles lists roles the Authorizer should require, usually given to Method.
+// Wrap fails if roles are set without an Authorizer.
+func WithRoles(roles ...string) Option {
+	return func(c *config) {
+		c.roles = append(c.roles[:len(c.roles):len(c.roles)], roles...)
+	}
+}
+
+// WithScopes lists OAuth style scopes the Authorizer should require. Wrap fails
+// if scopes are set without an Authorizer.
+func WithScopes(scopes ...string) Option {
+	return func(c *config) {
+		c.scopes = append(c.scopes[:len(c.scopes):len(c.scopes)], scopes...)
+	}
+}
+
+// WithContext adds values from the request (i.e. the Principal from a token)
+// to the context passed to services. Functions run in the order added.
+func WithContext(f ContextFunc) Option {
+	return func(c *config) {
+		c.contexts = append(c.contexts[:len(c.contexts):len(c.contexts)], f)
+	}
+}
+
+// WithDevMode includes the stack of recovered panics in the response. Never
+// use it in production.
+func WithDevMode() Option {
+	return func(c *config) {
+		c.devMode = true
+	}
+}
+
+// WithTimeout cancels the context of a method call after d and answers 504 if
+// it hasn't returned. Clients can ask for less with the Request-Timeout header.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithRateLimit allows each client limit calls per period with a token bucket
+// per method, so bursts of up to limit calls are allowed. Clients over the
+// limit get a 429. Use WithRateLimit(0, 0) in Method to turn it off.
+func WithRateLimit(limit int, per time.Duration) Option {
+	return func(c *config) {
+		c.rateLimit = limit
+		c.ratePer = per
+	}
+}
+
+// WithRateLimitKey changes how clients are told apart, i.e. by an API key
+// header read with RequestFromContext. The default is DefaultRateLimitKey.
+func WithRateLimitKey(key RateLimitKey) Option {
+	return func(c *config) {
+		c.rateKey = key
+	}
+}
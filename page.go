@@ -0,0 +1,254 @@
+package servicehandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum PageParams.PerPage (see WithPageLimits)
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// PageParams can be embedded in the parameters of a paginated method. Offset
+// pagination uses Page and PerPage while cursor pagination uses Cursor (the
+// NextCursor of the last page) and PerPage.
+//
+// PerPage defaults to 20 and is capped at 100 unless changed with
+// WithPageLimits. Page starts at 1.
+type PageParams struct {
+	Page    int
+	PerPage int
+	Cursor  string
+}
+
+// Offset of the first item on the page
+func (p PageParams) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// Page of results returned by a paginated method
+type Page[T any] struct {
+	Items      []T
+	Total      int    // Total number of items, 0 if unknown
+	NextCursor string // Value for the next page's Cursor, "" on the last page
+}
+
+func (p Page[T]) page() (interface{}, int, int, string) {
+	items := p.Items
+	if items == nil {
+		items = []T{}
+	}
+	return items, len(p.Items), p.Total, p.NextCursor
+}
+
+// pager is implemented by all Page[T] types
+type pager interface {
+	page() (items interface{}, count, total int, nextCursor string)
+}
+
+// asPager returns the result as a pager. A nil *Page[T] is an empty page.
+func asPager(result interface{}) (pager, bool) {
+	p, ok := result.(pager)
+	if !ok {
+		return nil, false
+	}
+
+	if v := reflect.ValueOf(result); v.Kind() == reflect.Ptr && v.IsNil() {
+		p = reflect.Zero(v.Type().Elem()).Interface().(pager)
+	}
+	return p, true
+}
+
+// PageMeta describes the page sent to the client
+type PageMeta struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PageResult is the result an Envelope receives for a Page[T]
+type PageResult struct {
+	Items interface{}
+	Meta  PageMeta
+}
+
+// pageParams of a method found when wrapping the service
+type pageParams struct {
+	index                 []int  // of the PageParams field
+	page, perPage, cursor string // Query keys
+}
+
+var pageParamsType = reflect.TypeOf(PageParams{})
+
+// findPageParams looks for an embedded PageParams among the parameter fields
+func findPageParams(t reflect.Type, fields []paramField) *pageParams {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	field, ok := t.FieldByName("PageParams")
+	if !ok || !field.Anonymous || field.Type != pageParamsType {
+		return nil
+	}
+
+	p := &pageParams{index: field.Index}
+	for _, f := range fields {
+		if len(f.index) != len(p.index)+1 || !hasPrefix(f.index, p.index) {
+			continue
+		}
+
+		switch f.field.Name {
+		case "Page":
+			p.page = f.query
+		case "PerPage":
+			p.perPage = f.query
+		case "Cursor":
+			p.cursor = f.query
+		}
+	}
+
+	return p
+}
+
+func hasPrefix(index, prefix []int) bool {
+	for i := range prefix {
+		if index[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// preparePage applies the limits to the PageParams in object and decodes the
+// signed cursor token
+func (c *config) preparePage(p *pageParams, object reflect.Value) FieldErrors {
+	params := reflect.Indirect(object).FieldByIndex(p.index).Addr().Interface().(*PageParams)
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+
+	if params.PerPage < 1 {
+		params.PerPage = c.perPage
+	} else if params.PerPage > c.maxPerPage {
+		params.PerPage = c.maxPerPage
+	}
+
+	if params.Cursor != "" && c.cursorSecret != nil {
+		cursor, ok := verifyCursor(c.cursorSecret, params.Cursor)
+		if !ok {
+			return FieldErrors{"Cursor": "is invalid"}
+		}
+		params.Cursor = cursor
+	}
+
+	return nil
+}
+
+// pageResult adds the RFC 8288 Link headers and meta data for a Page[T]
+func (c *config) pageResult(w http.ResponseWriter, r *http.Request, p *pageParams, object reflect.Value, result pager) PageResult {
+	items, count, total, next := result.page()
+
+	if next != "" && c.cursorSecret != nil {
+		next = signCursor(c.cursorSecret, next)
+	}
+
+	meta := PageMeta{
+		Total:      total,
+		NextCursor: next,
+	}
+
+	// Without PageParams there is nothing to link to
+	if p == nil {
+		return PageResult{Items: items, Meta: meta}
+	}
+
+	params := reflect.Indirect(object).FieldByIndex(p.index).Interface().(PageParams)
+	meta.PerPage = params.PerPage
+
+	// Cursor pages don't have numbers
+	if params.Cursor == "" && next == "" {
+		meta.Page = params.Page
+	}
+
+	var links []string
+	link := func(rel string, set map[string]string) {
+		u := *r.URL
+		q := u.Query()
+		for k, v := range set {
+			if v == "" {
+				q.Del(k)
+			} else {
+				q.Set(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+		links = append(links, "<"+u.RequestURI()+">; rel=\""+rel+"\"")
+	}
+
+	perPage := strconv.Itoa(params.PerPage)
+
+	if next != "" || params.Cursor != "" {
+		if next != "" {
+			link("next", map[string]string{p.cursor: next, p.perPage: perPage, p.page: ""})
+		}
+		link("first", map[string]string{p.cursor: "", p.perPage: perPage, p.page: ""})
+	} else {
+		lastPage := 0
+		if total > 0 && params.PerPage > 0 {
+			lastPage = (total + params.PerPage - 1) / params.PerPage
+		}
+
+		if params.Page > 1 {
+			link("prev", map[string]string{p.page: strconv.Itoa(params.Page - 1), p.perPage: perPage})
+		}
+		if (lastPage > 0 && params.Page < lastPage) || (total == 0 && count == params.PerPage) {
+			link("next", map[string]string{p.page: strconv.Itoa(params.Page + 1), p.perPage: perPage})
+		}
+		link("first", map[string]string{p.page: "1", p.perPage: perPage})
+		if lastPage > 0 {
+			link("last", map[string]string{p.page: strconv.Itoa(lastPage), p.perPage: perPage})
+		}
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+	if total > 0 {
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	}
+
+	return PageResult{Items: items, Meta: meta}
+}
+
+// signCursor turns a cursor value into an opaque token clients can't forge
+func signCursor(secret []byte, cursor string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(cursor))
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func verifyCursor(secret []byte, token string) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i == -1 {
+		return "", false
+	}
+
+	cursor, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(signCursor(secret, string(cursor))), []byte(token)) {
+		return "", false
+	}
+
+	return string(cursor), true
+}
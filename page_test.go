@@ -0,0 +1,163 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type PageService struct{}
+
+// Cursor pagination over the numbers 1 to 5
+func (s *PageService) Numbers(ctx context.Context, params struct {
+	PageParams
+}) (Page[int], error) {
+	start, _ := strconv.Atoi(params.Cursor)
+
+	var page Page[int]
+	for i := start + 1; i <= 5 && len(page.Items) < params.PerPage; i++ {
+		page.Items = append(page.Items, i)
+	}
+
+	if last := start + len(page.Items); last < 5 {
+		page.NextCursor = strconv.Itoa(last)
+	}
+
+	return page, nil
+}
+
+func TestOffsetPagination(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{}, WithPageLimits(10, 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		URL      string
+		Link     string
+		Response string
+	}{
+		{
+			URL:      "/Recent",
+			Link:     `</Recent?Page=2&PerPage=10>; rel="next", </Recent?Page=1&PerPage=10>; rel="first", </Recent?Page=5&PerPage=10>; rel="last"`,
+			Response: `{"success":true,"data":[{"Name":"Alice","Email":""},{"Name":"Bob","Email":""}],"meta":{"page":1,"per_page":10,"total":50}}`,
+		},
+		{
+			URL:      "/Recent?Page=2&PerPage=100",
+			Link:     `</Recent?Page=1&PerPage=25>; rel="prev", </Recent?Page=1&PerPage=25>; rel="first", </Recent?Page=2&PerPage=25>; rel="last"`,
+			Response: `{"success":true,"data":[{"Name":"Alice","Email":""},{"Name":"Bob","Email":""}],"meta":{"page":2,"per_page":25,"total":50}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("GET", s.URL, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if link := rr.Header().Get("Link"); link != s.Link {
+			t.Errorf("%s: wrong Link:\ngot %s\nwant %s", s.URL, link, s.Link)
+		}
+
+		if total := rr.Header().Get("X-Total-Count"); total != "50" {
+			t.Errorf("%s: wrong X-Total-Count: %s", s.URL, total)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s: wrong response:\ngot %s\nwant %s", s.URL, got, s.Response)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+
+	mux, err := Wrap(&PageService{}, WithCursorSecret([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Follow the next links to the end
+	var items []string
+	next := "/Numbers?PerPage=2"
+	for pages := 0; next != ""; pages++ {
+		if pages == 5 {
+			t.Fatal("Too many pages")
+		}
+
+		req := httptest.NewRequest("GET", next, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != 200 {
+			t.Fatalf("%s: wrong status code %d: %s", next, rr.Code, rr.Body.String())
+		}
+
+		body := strings.TrimSpace(rr.Body.String())
+		items = append(items, body[strings.Index(body, "[")+1:strings.Index(body, "]")])
+
+		next = ""
+		for _, link := range strings.Split(rr.Header().Get("Link"), ", ") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				next = link[1:strings.Index(link, ">")]
+			}
+		}
+	}
+
+	if got := strings.Join(items, ","); got != "1,2,3,4,5" {
+		t.Errorf("Wrong items: %s", got)
+	}
+
+	// Tampered cursors are rejected
+	forged := url.QueryEscape(signCursor([]byte("guess"), "2"))
+	req := httptest.NewRequest("GET", "/Numbers?Cursor="+forged, nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	want := `{"success":false,"error":"Invalid Request","fields":{"Cursor":"is invalid"}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("Wrong response:\ngot %s\nwant %s", got, want)
+	}
+}
+
+func TestPageLimits(t *testing.T) {
+
+	for _, limits := range [][2]int{{0, 100}, {20, 0}, {50, 10}, {-1, -1}} {
+		if _, err := Wrap(&TestUserService{}, WithPageLimits(limits[0], limits[1])); err == nil {
+			t.Errorf("%v: expected an error", limits)
+		}
+	}
+
+	if _, err := Wrap(&TestUserService{}, Method("Recent", WithPageLimits(0, 10))); err == nil {
+		t.Error("Expected an error for per-method limits")
+	}
+
+	if _, err := Wrap(&TestUserService{}, WithPageLimits(10, 10)); err != nil {
+		t.Error(err)
+	}
+}
+
+func (s *PageService) Missing(ctx context.Context, params struct {
+	PageParams
+}) (*Page[int], error) {
+	return nil, nil
+}
+
+func TestNilPage(t *testing.T) {
+
+	mux, err := Wrap(&PageService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Missing", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	want := `{"success":true,"data":[],"meta":{"page":1,"per_page":20}}`
+	if got := strings.TrimSpace(rr.Body.String()); rr.Code != 200 || got != want {
+		t.Errorf("Wrong response %d:\ngot  %s\nwant %s", rr.Code, got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package servicehandler
+
+import (
+	"net/http"
+)
+
+// Problem is an RFC 7807 "problem detail" document
+// https://tools.ietf.org/html/rfc7807
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"` // Invalid fields
+	Codes    map[string]string `json:"codes,omitempty"`  // Failed rule of each field
+	Code     string            `json:"code,omitempty"`
+	ErrorID  string            `json:"error_id,omitempty"`
+	Stack    string            `json:"stack,omitempty"`
+}
+
+// NewProblem document describing the error
+func NewProblem(r *http.Request, e *ResponseError) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Message,
+		Instance: r.URL.Path,
+		Errors:   e.Fields,
+		Codes:    e.Codes,
+		Code:     e.Code,
+		ErrorID:  e.ErrorID,
+		Stack:    e.Stack,
+	}
+}
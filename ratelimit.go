@@ -0,0 +1,115 @@
+package servicehandler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitKey identifies the client a request is counted against
+type RateLimitKey func(ctx context.Context) string
+
+// DefaultRateLimitKey is the Principal (if set) or else the RemoteIP
+func DefaultRateLimitKey(ctx context.Context) string {
+	if p := Principal(ctx); p != nil {
+		return "principal:" + fmt.Sprint(p)
+	}
+	return "ip:" + RemoteIP(ctx)
+}
+
+// limiter is a token bucket per client for one method. Each bucket holds up
+// to limit tokens and refills completely over per.
+type limiter struct {
+	limit int
+	per   time.Duration
+	key   RateLimitKey
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newLimiter(limit int, per time.Duration, key RateLimitKey) *limiter {
+	if limit <= 0 || per <= 0 {
+		return nil
+	}
+	if key == nil {
+		key = DefaultRateLimitKey
+	}
+	return &limiter{
+		limit:   limit,
+		per:     per,
+		key:     key,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+// take a token for the client. wait is how long until the next token (when
+// not allowed) and reset how long until the bucket is full again.
+func (l *limiter) take(key string, now time.Time) (allowed bool, remaining int, wait, reset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Idle buckets are full again so they can be dropped
+	if now.Sub(l.swept) >= l.per {
+		for k, b := range l.buckets {
+			if now.Sub(b.updated) >= l.per {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+
+	rate := float64(l.limit) / l.per.Seconds() // Tokens per second
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	reset = time.Duration((float64(l.limit) - b.tokens) / rate * float64(time.Second))
+	return allowed, int(b.tokens), wait, reset
+}
+
+// allow reports if the request is within the limit and sets the RateLimit-*
+// headers (and Retry-After when it isn't)
+func (l *limiter) allow(w http.ResponseWriter, r *http.Request) bool {
+	allowed, remaining, wait, reset := l.take(l.key(r.Context()), time.Now())
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(l.limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("RateLimit-Reset", seconds(reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(l.limit)+";w="+seconds(l.per))
+
+	if !allowed {
+		h.Set("Retry-After", seconds(wait))
+	}
+	return allowed
+}
+
+// seconds rounded up for headers
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
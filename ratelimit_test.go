@@ -0,0 +1,96 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+
+	mux, err := Wrap(&TestUserService{},
+		WithRateLimit(2, time.Minute),
+		Method("Recent", WithRateLimit(0, 0)),
+		WithRateLimitKey(func(ctx context.Context) string {
+			r, _ := RequestFromContext(ctx)
+			return r.Header.Get("X-API-Key")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rr := get("/Get?ID=1", "a")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: wrong status %d", i, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("Request %d: wrong RateLimit-Remaining: got %s want %s", i, got, remaining)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" || rr.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("Request %d: wrong headers: %v", i, rr.Header())
+		}
+	}
+
+	rr := get("/Get?ID=1", "a")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Wrong status: got %d want 429", rr.Code)
+	}
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"success":false,"error":"too many requests"}` {
+		t.Errorf("Wrong response: %s", got)
+	}
+	if rr.Header().Get("Retry-After") != "30" || rr.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("Wrong headers: %v", rr.Header())
+	}
+
+	// Other clients and methods have their own buckets
+	if rr = get("/Get?ID=1", "b"); rr.Code != http.StatusOK {
+		t.Errorf("Other client limited: %d", rr.Code)
+	}
+	if rr = get("/Remove?ID=2", "a"); rr.Code != http.StatusOK {
+		t.Errorf("Other method limited: %d", rr.Code)
+	}
+	if rr = get("/Recent", "a"); rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Unlimited method limited: %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := newLimiter(10, 10*time.Second, nil)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		if ok, _, _, _ := l.take("a", now); !ok {
+			t.Fatalf("Token %d refused", i)
+		}
+	}
+
+	if ok, _, wait, _ := l.take("a", now); ok || wait != time.Second {
+		t.Fatalf("Empty bucket: allowed %v wait %s", ok, wait)
+	}
+
+	// One token a second
+	if ok, remaining, _, _ := l.take("a", now.Add(1500*time.Millisecond)); !ok || remaining != 0 {
+		t.Errorf("Refill: allowed %v remaining %d", ok, remaining)
+	}
+
+	l.take("b", now.Add(5*time.Second))
+
+	// Idle buckets are dropped once full again
+	l.take("c", now.Add(12*time.Second))
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 2 {
+		t.Errorf("Idle buckets kept: %v", l.buckets)
+	}
+}
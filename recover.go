@@ -0,0 +1,48 @@
+package servicehandler
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic answers a panic in a service method (or any hook around it)
+// with a 500 and logs the stack under the error ID the client is given. A
+// stream or download that already started is aborted instead, a JSON error
+// can't be added to its body.
+func (c *config) recoverPanic(w http.ResponseWriter, r *http.Request, method string, p interface{}) {
+	if p == nil {
+		return
+	}
+
+	stack := debug.Stack()
+
+	// From the goroutine of a call with a deadline
+	if cp, ok := p.(*callPanic); ok {
+		p, stack = cp.value, cp.stack
+	}
+
+	// Used by net/http to abort a response on purpose
+	if p == http.ErrAbortHandler {
+		panic(p)
+	}
+
+	id := newErrorID()
+	c.logger.Printf("servicehandler: panic %s in %s: %v\n%s", id, method, p, stack)
+
+	// The client sees a cut off response rather than a complete one
+	if responseStarted(r) {
+		panic(http.ErrAbortHandler)
+	}
+
+	e := &ResponseError{
+		Status:  http.StatusInternalServerError,
+		ErrorID: id,
+		Err:     fmt.Errorf("panic: %v", p),
+	}
+	if c.devMode {
+		e.Stack = string(stack)
+	}
+
+	c.write(w, r, nil, e)
+}
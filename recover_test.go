@@ -0,0 +1,122 @@
+package servicehandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"iter"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type PanicService struct{}
+
+func (s *PanicService) Crash(ctx context.Context, params struct{}) (int, error) {
+	var users map[string]int
+	users["alice"]++ // nil map
+	return 0, nil
+}
+
+func (s *PanicService) Abort(ctx context.Context, params struct{}) error {
+	panic(http.ErrAbortHandler)
+}
+
+func (s *PanicService) Stream(ctx context.Context, params struct{}) (iter.Seq2[int, error], error) {
+	return func(yield func(int, error) bool) {
+		if !yield(1, nil) {
+			return
+		}
+		panic("lost the cursor")
+	}, nil
+}
+
+func TestPanicRecovery(t *testing.T) {
+
+	for _, dev := range []bool{false, true} {
+		var logs bytes.Buffer
+		options := []Option{WithErrorLog(log.New(&logs, "", 0))}
+		if dev {
+			options = append(options, WithDevMode())
+		}
+
+		mux, err := Wrap(&PanicService{}, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest("GET", "/Crash", nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+		}
+
+		var response JSONResponse
+		if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.ErrorID == "" || response.Error != "Internal Server Error" {
+			t.Errorf("Wrong response: %s", rr.Body.String())
+		}
+
+		if dev != strings.Contains(response.Stack, "PanicService") {
+			t.Errorf("Wrong stack in dev mode %v: %q", dev, response.Stack)
+		}
+
+		prefix := "servicehandler: panic " + response.ErrorID + " in PanicService.Crash: assignment to entry in nil map"
+		if !strings.HasPrefix(logs.String(), prefix) || !strings.Contains(logs.String(), "recover_test.go") {
+			t.Errorf("Wrong log:\n%s", logs.String())
+		}
+	}
+}
+
+func TestPanicAbortHandler(t *testing.T) {
+
+	mux, err := Wrap(&PanicService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be re-panicked, got %v", p)
+		}
+	}()
+
+	req := httptest.NewRequest("GET", "/Abort", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestPanicAfterStreamStarted(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&PanicService{}, WithErrorLog(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/Stream", nil)
+	rr := httptest.NewRecorder()
+
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("Expected http.ErrAbortHandler, got %v", p)
+			}
+		}()
+		mux.ServeHTTP(rr, req)
+	}()
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "1\n" {
+		t.Errorf("Wrong response: %d %q", rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "in PanicService.Stream: lost the cursor") {
+		t.Errorf("Wrong log:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,65 @@
+package servicehandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Buffers larger than this are not kept in the pool so one large response
+// doesn't keep the memory around forever
+const maxPooledBuffer = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// encodeJSON into a pooled buffer. Return it with putBuffer when done.
+func encodeJSON(i interface{}) (*bytes.Buffer, error) {
+	b := bufferPool.Get().(*bytes.Buffer)
+	err := json.NewEncoder(b).Encode(i)
+	return b, err
+}
+
+// writeBuffer sends the encoded body with the headers set before the status
+func writeBuffer(w http.ResponseWriter, status int, contentType string, b *bytes.Buffer) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(status)
+	_, _ = w.Write(b.Bytes())
+}
+
+// JSON response helper. It doesn't set the status so it can follow a call to
+// w.WriteHeader, otherwise the response is a 200. If i can't be encoded a 500
+// JSONResponse is sent instead.
+func JSON(w http.ResponseWriter, i interface{}) {
+	b, err := encodeJSON(i)
+	defer putBuffer(b)
+
+	if err != nil {
+		b.Reset()
+		_ = json.NewEncoder(b).Encode(JSONResponse{
+			Success: false,
+			Error:   http.StatusText(http.StatusInternalServerError),
+		})
+		writeBuffer(w, http.StatusInternalServerError, "application/json", b)
+		return
+	}
+
+	// Ignored by net/http if the status was already written
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b.Bytes())
+}
@@ -0,0 +1,165 @@
+package servicehandler
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Services can stream results by returning a channel or an iterator:
+//
+//	func (...) (<-chan T, error)
+//	func (...) (iter.Seq2[T, error], error)
+//
+// Each item is sent as a line of NDJSON or, if the client accepts
+// text/event-stream, as a Server-Sent Event. The stream ends when the channel
+// is closed, the iterator finishes or the client disconnects.
+type streamKind int
+
+const (
+	notStream streamKind = iota
+	chanStream
+	seqStream
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// streamType checks if a method result is a receivable channel or an
+// iter.Seq2[T, error] (func(yield func(T, error) bool))
+func streamType(t reflect.Type) streamKind {
+	switch t.Kind() {
+	case reflect.Chan:
+		if t.ChanDir()&reflect.RecvDir != 0 {
+			return chanStream
+		}
+	case reflect.Func:
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return notStream
+		}
+
+		yield := t.In(0)
+		if yield.Kind() == reflect.Func && yield.NumIn() == 2 && yield.In(1) == errorType &&
+			yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool {
+			return seqStream
+		}
+	}
+	return notStream
+}
+
+// streamWriter sends each item as soon as it is ready
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request) *streamWriter {
+	s := &streamWriter{w: w}
+	s.flusher, _ = w.(http.Flusher)
+	s.sse = strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	h := w.Header()
+	if s.sse {
+		h.Set("Content-Type", "text/event-stream")
+	} else {
+		h.Set("Content-Type", "application/x-ndjson")
+	}
+	h.Set("Cache-Control", "no-cache")
+	startResponse(r)
+	w.WriteHeader(http.StatusOK)
+	s.flush()
+
+	return s
+}
+
+// write one item (or error event) reporting if the client is still there
+func (s *streamWriter) write(event string, i interface{}) bool {
+	b, err := encodeJSON(i)
+	defer putBuffer(b)
+	if err != nil {
+		return false
+	}
+
+	if s.sse {
+		if event != "" {
+			if _, err = s.w.Write([]byte("event: " + event + "\n")); err != nil {
+				return false
+			}
+		}
+		// encodeJSON ends with a newline, SSE events end with a blank line
+		b.WriteByte('\n')
+		if _, err = s.w.Write([]byte("data: ")); err != nil {
+			return false
+		}
+	}
+
+	if _, err = s.w.Write(b.Bytes()); err != nil {
+		return false
+	}
+
+	s.flush()
+	return true
+}
+
+func (s *streamWriter) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// stream the channel or iterator returned by the service method
+func (c *config) stream(w http.ResponseWriter, r *http.Request, method *serviceMethod, result reflect.Value) {
+	ctx := r.Context()
+	s := newStreamWriter(w, r)
+
+	switch method.stream {
+	case chanStream:
+		if result.IsNil() {
+			return
+		}
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: result},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		}
+
+		for {
+			chosen, item, ok := reflect.Select(cases)
+			if chosen == 1 || !ok {
+				return
+			}
+
+			if !s.write("", item.Interface()) {
+				return
+			}
+		}
+
+	case seqStream:
+		if result.IsNil() {
+			return
+		}
+
+		yield := reflect.MakeFunc(result.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(c.yield(ctx, r, s, method, args[0], args[1]))}
+		})
+
+		result.Call([]reflect.Value{yield})
+	}
+}
+
+// yield sends an item from an iterator. An error ends the stream with an
+// error event (hiding internal errors like any other response).
+func (c *config) yield(ctx context.Context, r *http.Request, s *streamWriter, method *serviceMethod, item, errValue reflect.Value) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err, _ := errValue.Interface().(error); err != nil {
+		_, _, body := c.render(r, nil, c.serviceFailure(r, method.name, method.names, err))
+		s.write("error", body)
+		return false
+	}
+
+	return s.write("", item.Interface())
+}
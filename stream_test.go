@@ -0,0 +1,120 @@
+package servicehandler
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type StreamService struct{}
+
+func (s *StreamService) Count(ctx context.Context, params struct {
+	To int
+}) (<-chan int, error) {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 1; params.To == 0 || i <= params.To; i++ {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *StreamService) Users(ctx context.Context, params struct{}) (iter.Seq2[*TestUser, error], error) {
+	return func(yield func(*TestUser, error) bool) {
+		if !yield(&TestUser{Name: "Alice"}, nil) {
+			return
+		}
+		if !yield(&TestUser{Name: "Bob"}, nil) {
+			return
+		}
+		yield(nil, &StatusError{Status: http.StatusGone, Message: "Carol left"})
+	}, nil
+}
+
+func TestStreams(t *testing.T) {
+
+	mux, err := Wrap(&StreamService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		Name        string
+		URL         string
+		Accept      string
+		ContentType string
+		Response    string
+	}{
+		{
+			Name:        "Channel NDJSON",
+			URL:         "/Count?To=3",
+			ContentType: "application/x-ndjson",
+			Response:    "1\n2\n3\n",
+		},
+		{
+			Name:        "Iterator NDJSON",
+			URL:         "/Users",
+			ContentType: "application/x-ndjson",
+			Response:    `{"Name":"Alice","Email":""}` + "\n" + `{"Name":"Bob","Email":""}` + "\n" + `{"success":false,"error":"Carol left"}` + "\n",
+		},
+		{
+			Name:        "Iterator SSE",
+			URL:         "/Users",
+			Accept:      "text/event-stream",
+			ContentType: "text/event-stream",
+			Response: "data: {\"Name\":\"Alice\",\"Email\":\"\"}\n\n" +
+				"data: {\"Name\":\"Bob\",\"Email\":\"\"}\n\n" +
+				"event: error\ndata: {\"success\":false,\"error\":\"Carol left\"}\n\n",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", s.URL, nil)
+			req.Header.Set("Accept", s.Accept)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if ct := rr.Header().Get("Content-Type"); ct != s.ContentType {
+				t.Errorf("Wrong content type: got %s want %s", ct, s.ContentType)
+			}
+
+			if !rr.Flushed {
+				t.Error("Stream was not flushed")
+			}
+
+			if got := rr.Body.String(); got != s.Response {
+				t.Errorf("Wrong response:\ngot %q\nwant %q", got, s.Response)
+			}
+		})
+	}
+}
+
+func TestStreamDisconnect(t *testing.T) {
+
+	mux, err := Wrap(&StreamService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A client that went away while the (endless) stream was sending
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Hangs if the stream doesn't stop
+	req := httptest.NewRequest("GET", "/Count", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
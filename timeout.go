@@ -0,0 +1,98 @@
+package servicehandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader lets clients shorten the deadline of a request, i.e. "2s" or
+// "500ms" (plain numbers are seconds). It can't exceed the WithTimeout value.
+const TimeoutHeader = "Request-Timeout"
+
+// ErrTimeout is sent when a method doesn't return before its deadline
+var ErrTimeout error = &StatusError{Status: http.StatusGatewayTimeout, Message: "timeout"}
+
+// requestTimeout from the header, 0 if missing or invalid
+func requestTimeout(r *http.Request) time.Duration {
+	value := r.Header.Get(TimeoutHeader)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// withDeadline adds the method timeout, shortened by the client, to r
+func (c *config) withDeadline(r *http.Request) (*http.Request, context.CancelFunc) {
+	timeout := c.timeout
+	if d := requestTimeout(r); d > 0 && (timeout == 0 || d < timeout) {
+		timeout = d
+	}
+
+	if timeout <= 0 {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+// callPanic carries a panic from the goroutine running the method
+type callPanic struct {
+	value interface{}
+	stack []byte
+}
+
+type callResult struct {
+	output interface{}
+	err    error
+	panic  *callPanic
+}
+
+// call the method and stop waiting for it when the deadline passes. Methods
+// should still watch ctx.Done() so the goroutine is freed.
+func call(ctx context.Context, invoke Invoker, params interface{}) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return invoke(ctx, params)
+	}
+
+	done := make(chan callResult, 1)
+	go func() {
+		var result callResult
+		defer func() {
+			if p := recover(); p != nil {
+				result.panic = &callPanic{value: p, stack: debug.Stack()}
+			}
+			done <- result
+		}()
+		result.output, result.err = invoke(ctx, params)
+	}()
+
+	select {
+	case result := <-done:
+		if result.panic != nil {
+			panic(result.panic)
+		}
+		if result.err != nil && errors.Is(result.err, context.DeadlineExceeded) && ctx.Err() != nil {
+			return nil, ErrTimeout
+		}
+		return result.output, result.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
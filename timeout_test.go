@@ -0,0 +1,134 @@
+package servicehandler
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SlowService struct{}
+
+// Respects the context
+func (s *SlowService) Wait(ctx context.Context, params struct {
+	Millis int
+}) (string, error) {
+	select {
+	case <-time.After(time.Duration(params.Millis) * time.Millisecond):
+		return "done", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Ignores the context
+func (s *SlowService) Sleep(ctx context.Context, params struct{}) (string, error) {
+	time.Sleep(200 * time.Millisecond)
+	return "done", nil
+}
+
+func (s *SlowService) Deadline(ctx context.Context, params struct{}) (bool, error) {
+	_, ok := ctx.Deadline()
+	return ok, nil
+}
+
+func (s *SlowService) Crash(ctx context.Context, params struct{}) (string, error) {
+	panic("slow crash")
+}
+
+func TestTimeouts(t *testing.T) {
+
+	var logs bytes.Buffer
+	mux, err := Wrap(&SlowService{},
+		WithErrorLog(log.New(&logs, "", 0)),
+		WithTimeout(time.Second),
+		Method("Sleep", WithTimeout(20*time.Millisecond)),
+		Method("Deadline", WithTimeout(0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		URL      string
+		Timeout  string
+		Status   int
+		Response string
+		Max      time.Duration
+	}{
+		{
+			URL:      "/Wait?Millis=10",
+			Status:   200,
+			Response: `{"success":true,"data":"done"}`,
+		},
+		{
+			URL:      "/Wait?Millis=500",
+			Timeout:  "20ms",
+			Status:   504,
+			Response: `{"success":false,"error":"timeout"}`,
+			Max:      200 * time.Millisecond,
+		},
+		{
+			// Clients can't extend the deadline
+			URL:      "/Sleep",
+			Timeout:  "10",
+			Status:   504,
+			Response: `{"success":false,"error":"timeout"}`,
+			Max:      150 * time.Millisecond,
+		},
+		{
+			URL:      "/Deadline",
+			Status:   200,
+			Response: `{"success":true,"data":false}`,
+		},
+		{
+			URL:      "/Deadline",
+			Timeout:  "0.5",
+			Status:   200,
+			Response: `{"success":true,"data":true}`,
+		},
+		{
+			URL:      "/Wait?Millis=10",
+			Timeout:  "soon",
+			Status:   200,
+			Response: `{"success":true,"data":"done"}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest("GET", s.URL, nil)
+		if s.Timeout != "" {
+			req.Header.Set(TimeoutHeader, s.Timeout)
+		}
+		rr := httptest.NewRecorder()
+
+		start := time.Now()
+		mux.ServeHTTP(rr, req)
+
+		if s.Max > 0 && time.Since(start) > s.Max {
+			t.Errorf("%s: took %s", s.URL, time.Since(start))
+		}
+
+		if rr.Code != s.Status {
+			t.Errorf("%s: wrong status: got %d want %d", s.URL, rr.Code, s.Status)
+		}
+
+		if got := strings.TrimSpace(rr.Body.String()); got != s.Response {
+			t.Errorf("%s: wrong response:\ngot  %s\nwant %s", s.URL, got, s.Response)
+		}
+	}
+
+	// Panics in the goroutine are still recovered with their stack
+	req := httptest.NewRequest("GET", "/Crash", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(logs.String(), "slow crash") ||
+		!strings.Contains(logs.String(), "timeout_test.go") {
+		t.Errorf("Wrong panic response %d:\n%s", rr.Code, logs.String())
+	}
+}
@@ -0,0 +1,147 @@
+package servicehandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// FieldErrors maps a parameter field name to the reason it is invalid
+type FieldErrors map[string]string
+
+func (f FieldErrors) Error() string {
+	errs := make([]string, 0, len(f))
+	for field, reason := range f {
+		errs = append(errs, field+": "+reason)
+	}
+	sort.Strings(errs)
+	return strings.Join(errs, "; ")
+}
+
+// HTTPStatus of invalid fields is always a Bad Request
+func (f FieldErrors) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// Public as the reasons are meant for the client
+func (f FieldErrors) Public() bool {
+	return true
+}
+
+// Validator checks a decoded parameter struct (or struct pointer) and returns
+// the invalid fields. A nil or empty FieldErrors means the value is valid.
+type Validator interface {
+	Validate(i interface{}) FieldErrors
+}
+
+// ValidatorFunc adapts a function to the Validator interface
+type ValidatorFunc func(i interface{}) FieldErrors
+
+// Validate calls f(i)
+func (f ValidatorFunc) Validate(i interface{}) FieldErrors {
+	return f(i)
+}
+
+// GoValidator validates structs using the `valid` tags of
+// https://github.com/asaskevich/govalidator (the default Validator)
+type GoValidator struct{}
+
+// Validate the struct with govalidator.ValidateStruct
+func (GoValidator) Validate(i interface{}) FieldErrors {
+	isValid, err := govalidator.ValidateStruct(i)
+	if isValid {
+		return nil
+	}
+
+	errs := FieldErrors(govalidator.ErrorsByField(err))
+
+	// Not a field error (i.e. an unsupported type) but still invalid
+	if len(errs) == 0 && err != nil {
+		errs[""] = err.Error()
+	}
+
+	return errs
+}
+
+// ParamValidator is implemented by parameter structs with business rules that
+// tags can't express such as "EndDate after StartDate". Validate is called
+// after the Validator and any FieldErrors returned are merged with the rest.
+type ParamValidator interface {
+	Validate(ctx context.Context) error
+}
+
+var paramValidatorType = reflect.TypeOf((*ParamValidator)(nil)).Elem()
+
+// implementsParamValidator checks the parameter type (or a pointer to it since
+// struct parameters are addressable) for a Validate(context.Context) method
+func implementsParamValidator(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	return t.Implements(paramValidatorType)
+}
+
+// validateHook runs the ParamValidator of object merging any FieldErrors into
+// errs (which take precedence). Other errors are returned as-is.
+func validateHook(ctx context.Context, object reflect.Value, errs FieldErrors) (FieldErrors, error) {
+	if object.Kind() != reflect.Ptr {
+		object = object.Addr()
+	}
+
+	err := object.Interface().(ParamValidator).Validate(ctx)
+	if err == nil {
+		return errs, nil
+	}
+
+	var fields FieldErrors
+	if !errors.As(err, &fields) {
+		return errs, err
+	}
+
+	if errs == nil {
+		errs = make(FieldErrors, len(fields))
+	}
+
+	for field, reason := range fields {
+		if _, ok := errs[field]; !ok {
+			errs[field] = reason
+		}
+	}
+
+	return errs, nil
+}
+
+// ValidateRules reports each failed govalidator rule
+func (GoValidator) ValidateRules(i interface{}) []RuleError {
+	isValid, err := govalidator.ValidateStruct(i)
+	if isValid {
+		return nil
+	}
+
+	rules := ruleErrors(err, nil)
+	if len(rules) == 0 && err != nil {
+		rules = append(rules, RuleError{Rule: "invalid", Message: err.Error()})
+	}
+	return rules
+}
+
+func ruleErrors(err error, rules []RuleError) []RuleError {
+	switch e := err.(type) {
+	case govalidator.Error:
+		rules = append(rules, RuleError{
+			Field:   e.Name,
+			Rule:    e.Validator,
+			Message: e.Err.Error(),
+		})
+	case govalidator.Errors:
+		for _, item := range e.Errors() {
+			rules = ruleErrors(item, rules)
+		}
+	}
+	return rules
+}
@@ -2,14 +2,11 @@ package servicehandler
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"path/filepath"
 	"reflect"
-
-	"github.com/asaskevich/govalidator"
 )
 
 // TagQuery is the field tag to define a query parameter's key
@@ -24,17 +21,37 @@ type JSONResponse struct {
 	Data    interface{}       `json:"data,omitempty"`
 	Error   string            `json:"error,omitempty"`
 	Fields  map[string]string `json:"fields,omitempty"`
+	Codes   map[string]string `json:"codes,omitempty"` // Failed rule of each field
+	Code    string            `json:"code,omitempty"`  // From errors implementing Coder
+	ErrorID string            `json:"error_id,omitempty"`
+	Stack   string            `json:"stack,omitempty"` // See WithDevMode
+	Meta    interface{}       `json:"meta,omitempty"`
 }
 
 // Wrapper for a service method
 type serviceMethod struct {
-	in        []reflect.Type
-	method    reflect.Value
-	anonymous bool
+	name       string  // Service.Method for logs
+	conf       *config // Includes the options for this method
+	in         []reflect.Type
+	method     reflect.Value
+	anonymous  bool
+	fields     []paramField
+	names      map[string]string // validation error field names
+	hook       bool              // params implement ParamValidator
+	localizer  RuleValidator     // Set when messages are translated
+	stream     streamKind        // Result is a channel or iterator
+	download   downloadKind      // Result is a File, io.Reader or []byte
+	page       *pageParams       // Params embed PageParams
+	selectable *allowedFields    // Result fields for WithSparseFields
+	info       MethodInfo
+	invoke     Invoker // Calls the method through the interceptors
+	limiter    *limiter
 }
 
 // Wrap a service with a http.Handler to respond to HTTP GET/POST requests
-func Wrap(service interface{}) (http.Handler, error) {
+func Wrap(service interface{}, options ...Option) (http.Handler, error) {
+
+	conf := newConfig(options)
 
 	// Improve performance (and clarity) by pre-computing needed variables
 	serviceType := reflect.TypeOf(service)
@@ -55,6 +72,7 @@ func Wrap(service interface{}) (http.Handler, error) {
 	for i := 0; i < serviceType.NumMethod(); i++ {
 		methodType := serviceType.Method(i)
 		method := methodType.Func
+		conf := conf.method(methodType.Name)
 
 		if methodType.Type.NumIn() != 3 {
 			return nil, fmt.Errorf("%s.%s(context.Context, struct{}) is the correct function signature.", serviceName, methodType.Name)
@@ -70,6 +88,9 @@ func Wrap(service interface{}) (http.Handler, error) {
 
 		// Marker for anonymous structs as parameters
 		var anonymous bool
+		var fields []paramField
+		var hook bool
+		var page *pageParams
 
 		for j := 0; j < methodType.Type.NumIn(); j++ {
 			paramType := methodType.Type.In(j)
@@ -114,26 +135,120 @@ func Wrap(service interface{}) (http.Handler, error) {
 				}
 			}
 
+			fields = paramFields(paramType, conf.naming)
+			hook = implementsParamValidator(paramType)
+			page = findPageParams(paramType, fields)
+		}
+
+		var stream streamKind
+		var download downloadKind
+		if methodType.Type.NumOut() == 2 {
+			stream = streamType(methodType.Type.Out(0))
+			download = downloadType(methodType.Type.Out(0))
+		}
+
+		var selectable *allowedFields
+		if conf.sparseFields && stream == notStream && download == notDownload && methodType.Type.NumOut() == 2 {
+			selectable = resultFields(itemType(methodType.Type.Out(0)), make(map[reflect.Type]*allowedFields))
+		}
+
+		if conf.perPage < 1 || conf.maxPerPage < conf.perPage {
+			return nil, fmt.Errorf("%s.%s() page limits need 1 <= perPage (%d) <= max (%d).", serviceName, methodType.Name, conf.perPage, conf.maxPerPage)
+		}
+
+		// Never silently skip a declared access rule
+		if conf.authorizer == nil && (len(conf.roles) > 0 || len(conf.scopes) > 0) {
+			return nil, fmt.Errorf("%s.%s() requires roles or scopes but no Authorizer is set.", serviceName, methodType.Name)
+		}
+
+		// Validation messages are only translated if the validator reports rules
+		var localizer RuleValidator
+		if v, ok := conf.validator.(RuleValidator); ok && conf.catalog != nil {
+			localizer = v
 		}
 
 		name := methodType.Name
-		methods[name] = &serviceMethod{
-			in:        in,
-			anonymous: anonymous,
-			method:    method,
+		m := &serviceMethod{
+			name:       serviceName + "." + name,
+			conf:       conf,
+			in:         in,
+			anonymous:  anonymous,
+			method:     method,
+			fields:     fields,
+			names:      errorNames(fields),
+			hook:       hook,
+			localizer:  localizer,
+			stream:     stream,
+			download:   download,
+			page:       page,
+			selectable: selectable,
+			limiter:    newLimiter(conf.rateLimit, conf.ratePer, conf.rateKey),
+			info: MethodInfo{
+				Service: serviceName,
+				Name:    name,
+				Roles:   conf.roles,
+				Scopes:  conf.scopes,
+
+				ErrorCodes: conf.errorCodes,
+			},
+		}
+		m.invoke = intercept(m.invoker(serviceValue), m.info, conf.interceptors)
+		m.invoke = authorize(m.invoke, m.info, conf.authorizer)
+		methods[name] = m
+	}
+
+	// A typo in Method() would otherwise drop its options (roles, limits...)
+	for name := range conf.methods {
+		if _, ok := methods[name]; !ok {
+			return nil, fmt.Errorf("%s has no method %s for the options given to Method(%q).", serviceName, name, name)
 		}
 	}
 
 	// Cache setup finished, now get ready to process requests
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	serve := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		name := filepath.Base(r.URL.Path)
 		method, ok := methods[name]
 
 		if !ok {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			conf.writeStatus(w, r, http.StatusNotFound)
 			return
 		}
 
+		conf := method.conf
+		defer func() {
+			conf.recoverPanic(w, r, method.name, recover())
+		}()
+
+		r, cancel := conf.withDeadline(r)
+		defer cancel()
+
+		r = conf.requestContext(r)
+
+		// Rate limit before decoding anything
+		if method.limiter != nil && !method.limiter.allow(w, r) {
+			conf.write(w, r, nil, &ResponseError{
+				Status:  http.StatusTooManyRequests,
+				Message: "too many requests",
+			})
+			return
+		}
+
+		// Check the sparse fieldset before doing any work
+		var selected fieldTree
+		if method.selectable != nil {
+			if value := r.URL.Query().Get(FieldsParam); value != "" {
+				var errs FieldErrors
+				if selected, errs = method.selectable.parse(value); errs != nil {
+					conf.write(w, r, nil, &ResponseError{
+						Status:  http.StatusBadRequest,
+						Message: conf.invalidRequest(r),
+						Fields:  errs,
+					})
+					return
+				}
+			}
+		}
+
 		in := make([]reflect.Value, len(method.in))
 
 		for i, paramType := range method.in {
@@ -163,33 +278,24 @@ func Wrap(service interface{}) (http.Handler, error) {
 
 			if r.Method == http.MethodGet {
 				if !method.anonymous {
-					http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+					conf.writeStatus(w, r, http.StatusMethodNotAllowed)
 					return
 				}
 
-				numFields := paramType.NumField()
-				queryValues := r.URL.Query()
-				for j := 0; j < numFields; j++ {
-					field := paramType.Field(j)
-
-					var s string
-					tag, ok := field.Tag.Lookup(TagQuery)
-					if ok {
-						s = queryValues.Get(tag)
-					} else {
-						s = queryValues.Get(field.Name)
-					}
+				get := queryGetter(r.URL.Query(), conf.queryIgnoreCase)
+				for _, f := range method.fields {
+					s := get(f.query)
 
-					// fmt.Printf("Field: %v = %q\n", field.Name, s)
+					// fmt.Printf("Field: %v = %q\n", f.field.Name, s)
 
 					if s == "" {
 						// Do not fail right now, it is the job of validator
 						continue
 					}
 
-					val := object.Field(j)
+					val := object.FieldByIndex(f.index)
 
-					err := parseSimpleParam(s, "Query Parameter", field, &val)
+					err := parseSimpleParam(s, "Query Parameter", f.field, &val)
 					if err != nil {
 						// fmt.Println(err)
 						// What should we do here?
@@ -201,7 +307,7 @@ func Wrap(service interface{}) (http.Handler, error) {
 			} else if r.Method == "POST" {
 
 				if method.anonymous {
-					http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+					conf.writeStatus(w, r, http.StatusMethodNotAllowed)
 					return
 				}
 
@@ -209,24 +315,52 @@ func Wrap(service interface{}) (http.Handler, error) {
 				// JSON structure: https://golang.org/src/net/http/request.go#L1148
 				r := io.LimitReader(r.Body, MaxBodySize)
 
-				oi := object.Interface()
-
 				// We don't care about JSON type errors nor want to give app details out
 				// The validator will handle those messages better below
-				_ = json.NewDecoder(r).Decode(&oi)
+				_ = decodeBody(r, object, method.fields, conf.naming)
+			}
+
+			// Apply the page limits before the validator sees them
+			var pageErrs FieldErrors
+			if method.page != nil {
+				pageErrs = conf.preparePage(method.page, object)
 			}
 
 			// 2. Validate the struct data rules
-			isValid, err := govalidator.ValidateStruct(object.Interface())
+			var errs FieldErrors
+			var codes map[string]string
+			if method.localizer != nil {
+				errs, codes = localize(requestLocale(r, conf.catalog), conf.catalog, method.localizer, object.Interface(), method.names)
+			} else {
+				errs = conf.validator.Validate(object.Interface())
+			}
+
+			for field, reason := range pageErrs {
+				if errs == nil {
+					errs = make(FieldErrors)
+				}
+				errs[field] = reason
+			}
+
+			// 3. Then any business rules the struct defines itself
+			var hookErr error
+			if method.hook {
+				errs, hookErr = validateHook(r.Context(), object, errs)
+			}
 
-			if !isValid {
-				validationErrors := govalidator.ErrorsByField(err)
+			// Other hook errors are handled like service errors so their
+			// status, code and (for internal errors) error ID apply
+			if hookErr != nil {
+				conf.write(w, r, nil, conf.serviceFailure(r, method.name, method.names, hookErr))
+				return
+			}
 
-				w.WriteHeader(http.StatusBadRequest)
-				JSON(w, JSONResponse{
-					Success: false,
-					Error:   "Invalid Request",
-					Fields:  validationErrors,
+			if len(errs) > 0 {
+				conf.write(w, r, nil, &ResponseError{
+					Status:  http.StatusBadRequest,
+					Message: conf.invalidRequest(r),
+					Fields:  renameFields(errs, method.names),
+					Codes:   renameFields(codes, method.names),
 				})
 				return
 			}
@@ -234,37 +368,56 @@ func Wrap(service interface{}) (http.Handler, error) {
 			in[i] = object
 		}
 
-		response := method.method.Call(in)
+		output, err := call(r.Context(), method.invoke, in[2].Interface())
+
+		// Headers and cookies the service set through the context
+		responseControl(r.Context()).apply(w)
+
+		if err != nil {
+			conf.write(w, r, nil, conf.serviceFailure(r, method.name, method.names, err))
+			return
+		}
 
 		// Expect all service methods in one of two forms:
 		// func (...) error
 		// func (...) (interface{}, error)
-		ek := 0
-		if method.method.Type().NumOut() == 2 {
-			ek = 1
+		resultType := method.method.Type()
+		if resultType.NumOut() < 2 {
+			conf.writeNoContent(w, r)
+			return
 		}
+		resultType = resultType.Out(0)
 
-		if err, ok := response[ek].Interface().(error); ok {
-			if err != nil {
-				// http.Error(w, err.Error(), http.StatusBadRequest)
-				JSON(w, JSONResponse{
-					Success: false,
-					Error:   err.Error(),
-				})
-				return
-			}
+		// Interceptors may have replaced the result with another type
+		response := reflect.ValueOf(output)
+		if !response.IsValid() {
+			response = reflect.Zero(resultType)
 		}
 
-		if ek == 0 {
+		if method.stream != notStream && response.Type() == resultType {
+			conf.stream(w, r, method, response)
 			return
 		}
 
-		JSON(w, JSONResponse{
-			Success: true,
-			Data:    response[0].Interface(),
-		})
+		if method.download != notDownload && downloadType(response.Type()) == method.download {
+			conf.download(w, r, method, response)
+			return
+		}
+
+		result := output
+		if p, ok := asPager(result); ok {
+			result = conf.pageResult(w, r, method.page, in[len(in)-1], p)
+		}
+
+		if len(selected) > 0 {
+			result = selectFields(result, selected)
+		}
+
+		conf.write(w, r, result, nil)
+
+	})
 
-	}), nil
+	return newWrappedHandler(serve, methods), nil
 }
 
 func newReflectType(t reflect.Type) reflect.Value {
@@ -280,17 +433,3 @@ func newReflectType(t reflect.Type) reflect.Value {
 func isContext(r reflect.Type) bool {
 	return r.Implements(reflect.TypeOf((*context.Context)(nil)).Elem())
 }
-
-// JSON response helper
-func JSON(w http.ResponseWriter, i interface{}) {
-	var err error
-	w.Header().Set("Content-Type", "application/json")
-	e := json.NewEncoder(w)
-	// e.SetIndent("", "  ")
-	err = e.Encode(i)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		// http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-	}
-}